@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+// writable reports whether the current user may create entries in dir.
+// Without access(2) it cannot tell, and assumes so.
+func writable(dir string) bool {
+	return true
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// writable reports whether the current user may create entries in dir.
+func writable(dir string) bool {
+	// W_OK from unistd.h
+	return syscall.Access(dir, 0x2) == nil
+}
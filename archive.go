@@ -0,0 +1,264 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Archive formats told apart by their leading bytes. Windows releases
+// are zip files, everything else is xz compressed tar. Gzip only shows up
+// in repackaged tarballs.
+const (
+	formatXZ   = "xz"
+	formatGzip = "gzip"
+	formatZip  = "zip"
+)
+
+// sniffArchive returns the format of src from its magic bytes, or "" for
+// anything else, which is left to tar to figure out. Going by content
+// rather than name keeps a zip downloaded to --tar-dest working.
+func sniffArchive(src string) (string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	magic := make([]byte, 6)
+	n, _ := io.ReadFull(f, magic)
+	switch magic = magic[:n]; {
+	case bytes.HasPrefix(magic, []byte("\xfd7zXZ\x00")):
+		return formatXZ, nil
+	case bytes.HasPrefix(magic, []byte("\x1f\x8b")):
+		return formatGzip, nil
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return formatZip, nil
+	}
+	return "", nil
+}
+
+// extractHoisted extracts src into dest and then drops the leading
+// directories every entry shares, which puts zig and lib at the top of
+// dest. Upstream tarballs wrap everything in a zig-<platform>-<version>
+// directory, repackaged ones are often flat. Working out the count after
+// extracting rather than listing the archive first means it is only
+// decompressed once. It returns how many directories were dropped.
+func extractHoisted(log Logger, src, dest, decompressor string, progress ProgressFunc) (int, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return 0, err
+	}
+	stage, err := os.MkdirTemp(dest, ".extract-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(stage)
+	if err := extractArchive(log, src, stage, 0, decompressor, progress); err != nil {
+		return 0, err
+	}
+
+	root, depth := stage, 0
+	for {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			return 0, err
+		}
+		if len(entries) != 1 || !entries[0].IsDir() {
+			break
+		}
+		root = filepath.Join(root, entries[0].Name())
+		depth++
+	}
+	return depth, mergeDir(root, dest)
+}
+
+// mergeDir moves the contents of src into dst, which tar would have
+// extracted into directly: directories present in both are merged and
+// anything else already in dst is replaced.
+func mergeDir(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		from, to := filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())
+		if info, err := os.Lstat(to); err == nil {
+			if info.IsDir() && e.IsDir() {
+				if err := mergeDir(from, to); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := os.RemoveAll(to); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractZip is extractArchive for zip files, which tar cannot be relied
+// on to read. Progress is reported in compressed bytes like for tarballs.
+func extractZip(src, dest string, strip int, progress ProgressFunc) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return zipError(err)
+	}
+	defer r.Close()
+
+	var total, done int64
+	for _, f := range r.File {
+		total += int64(f.CompressedSize64)
+	}
+	if progress != nil {
+		progress("extract", 0, total)
+	}
+
+	root := filepath.Clean(dest) + string(filepath.Separator)
+	var links []zipLink
+	for _, f := range r.File {
+		name := stripComponents(f.Name, strip)
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		if !strings.HasPrefix(target, root) {
+			return fmt.Errorf("zip entry %q points outside the destination", f.Name)
+		}
+		// Links are made last, so no entry is written through one
+		if f.Mode()&os.ModeSymlink != 0 {
+			links = append(links, zipLink{f, target})
+		} else if err := extractZipEntry(f, target); err != nil {
+			return err
+		}
+		done += int64(f.CompressedSize64)
+		if progress != nil {
+			progress("extract", done, total)
+		}
+	}
+
+	realDest, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		if err := l.extract(realDest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zipLink is a symlink entry of a zip and where it goes.
+type zipLink struct {
+	f      *zip.File
+	target string
+}
+
+// extract creates the link, refusing one that is absolute or leads
+// outside dest, the real path of the destination, at any point of its
+// resolution. Components that are links themselves are resolved on the
+// way, so a link to . cannot be used to climb out, and the link is not
+// created through one either.
+func (l zipLink) extract(dest string) error {
+	rc, err := l.f.Open()
+	if err != nil {
+		return zipError(err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return zipError(err)
+	}
+	link := string(data)
+
+	if err := os.MkdirAll(filepath.Dir(l.target), 0755); err != nil {
+		return err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(l.target))
+	if err != nil {
+		return err
+	}
+	if !withinDir(dest, dir) {
+		return fmt.Errorf("zip entry %q is placed through a link outside the destination", l.f.Name)
+	}
+	if filepath.IsAbs(link) || filepath.VolumeName(link) != "" {
+		return fmt.Errorf("zip entry %q links to the absolute path %s", l.f.Name, link)
+	}
+	cur := dir
+	for _, part := range strings.Split(filepath.ToSlash(link), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			cur = filepath.Dir(cur)
+		default:
+			cur = filepath.Join(cur, part)
+			if resolved, err := filepath.EvalSymlinks(cur); err == nil {
+				cur = resolved
+			}
+		}
+		if !withinDir(dest, cur) {
+			return fmt.Errorf("zip entry %q links outside the destination: %s", l.f.Name, link)
+		}
+	}
+	return os.Symlink(link, filepath.Join(dir, filepath.Base(l.target)))
+}
+
+// withinDir reports whether path is dir or below it. Both must be clean.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func extractZipEntry(f *zip.File, target string) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return os.MkdirAll(target, 0755)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return zipError(err)
+	}
+	defer rc.Close()
+
+	// Zips made on Windows carry no Unix permissions
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return zipError(err)
+	}
+	return out.Close()
+}
+
+// stripComponents drops the first n slash separated components of name,
+// like tar's --strip-components, returning "" when nothing is left.
+func stripComponents(name string, n int) string {
+	parts := strings.Split(strings.TrimSuffix(name, "/"), "/")
+	if len(parts) <= n {
+		return ""
+	}
+	return strings.Join(parts[n:], "/")
+}
+
+// zipError is tarError for zip files.
+func zipError(err error) error {
+	return fmt.Errorf("the zip archive appears corrupted (%v); try --force to re-download", err)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeTarGz writes a .tar.gz of files into dir and returns its path.
+// Names ending in a slash are directories.
+func writeTarGz(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(dir, "test.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0755, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(name, "/") {
+			hdr.Typeflag, hdr.Size = tar.TypeDir, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// zipEntry is a file of a test zip. A link holds the target of a symlink.
+type zipEntry struct {
+	name, content, link string
+}
+
+// writeZip writes a zip of entries into dir and returns its path.
+func writeZip(t *testing.T, dir string, entries ...zipEntry) string {
+	t.Helper()
+	path := filepath.Join(dir, "test.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		content := e.content
+		switch {
+		case e.link != "":
+			hdr.SetMode(os.ModeSymlink | 0777)
+			content = e.link
+		case strings.HasSuffix(e.name, "/"):
+			hdr.SetMode(os.ModeDir | 0755)
+		default:
+			hdr.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractZip(t *testing.T) {
+	dir := t.TempDir()
+	src := writeZip(t, dir,
+		zipEntry{name: "zig-windows-x86_64-0.13.0/"},
+		zipEntry{name: "zig-windows-x86_64-0.13.0/zig.exe", content: "MZ"},
+		zipEntry{name: "zig-windows-x86_64-0.13.0/lib/std/std.zig", content: "pub const x = 1;"},
+	)
+	dest := filepath.Join(dir, "out")
+	if err := extractArchive(silentLogger{}, src, dest, 1, "auto", nil); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"zig.exe": "MZ", "lib/std/std.zig": "pub const x = 1;"} {
+		got, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+}
+
+func TestExtractZipSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	tests := []struct {
+		name    string
+		entries []zipEntry
+		wantErr bool
+	}{
+		{"inside", []zipEntry{
+			{name: "lib/std/std.zig", content: "std"},
+			{name: "std.zig", link: "lib/std/std.zig"},
+			{name: "lib/up", link: "../std.zig"},
+		}, false},
+		{"absolute", []zipEntry{{name: "evil", link: "/etc/passwd"}}, true},
+		{"parent", []zipEntry{{name: "lib/evil", link: "../../outside"}}, true},
+		{"entry path", []zipEntry{{name: "../evil", content: "x"}}, true},
+		{"through a link to dot", []zipEntry{
+			{name: "here", link: "."},
+			{name: "evil", link: "here/../outside"},
+		}, true},
+		{"placed through a link", []zipEntry{
+			{name: "up", link: "lib/.."},
+			{name: "lib/", content: ""},
+			{name: "up/x", link: "lib"},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := writeZip(t, dir, tt.entries...)
+			dest := filepath.Join(dir, "out")
+			err := extractArchive(silentLogger{}, src, dest, 0, "auto", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if _, statErr := os.Lstat(filepath.Join(dir, "outside")); statErr == nil {
+				t.Error("something was created outside the destination")
+			}
+			if err != nil {
+				return
+			}
+			got, err := os.ReadFile(filepath.Join(dest, "std.zig"))
+			if tt.name == "inside" && (err != nil || string(got) != "std") {
+				t.Errorf("std.zig = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestExtractTarballDetectsStrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{"flat", map[string]string{
+			"zig":             "binary",
+			"lib/std/std.zig": "std",
+		}},
+		{"wrapped", map[string]string{
+			"zig-linux-x86_64-0.13.0/":                "",
+			"zig-linux-x86_64-0.13.0/zig":             "binary",
+			"zig-linux-x86_64-0.13.0/lib/std/std.zig": "std",
+		}},
+		{"nested twice", map[string]string{
+			"release/zig-linux-x86_64-0.13.0/zig":             "binary",
+			"release/zig-linux-x86_64-0.13.0/lib/std/std.zig": "std",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := writeTarGz(t, dir, tt.files)
+			dest := filepath.Join(dir, "out")
+			if err := extractTarball(silentLogger{}, src, dest, -1, "auto", nil); err != nil {
+				t.Fatal(err)
+			}
+			checkExtracted(t, dest, map[string]string{"zig": "binary", "lib/std/std.zig": "std"})
+		})
+	}
+}
+
+func TestExtractTarballDetectsStripZip(t *testing.T) {
+	dir := t.TempDir()
+	src := writeZip(t, dir,
+		zipEntry{name: "zig-windows-x86_64-0.13.0/zig.exe", content: "MZ"},
+		zipEntry{name: "zig-windows-x86_64-0.13.0/lib/std/std.zig", content: "std"},
+	)
+	dest := filepath.Join(dir, "out")
+	if err := extractTarball(silentLogger{}, src, dest, -1, "auto", nil); err != nil {
+		t.Fatal(err)
+	}
+	checkExtracted(t, dest, map[string]string{"zig.exe": "MZ", "lib/std/std.zig": "std"})
+}
+
+func TestExtractTarballMergesIntoDest(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "out")
+	if err := prepareDest(dest, false); err != nil {
+		t.Fatal(err)
+	}
+	// Leftovers of a previous run with --no-clean
+	fakeBinary(t, filepath.Join(dest, "zig"), "old")
+	fakeBinary(t, filepath.Join(dest, "lib", "old.zig"), "old")
+	src := writeTarGz(t, dir, map[string]string{
+		"zig-linux-x86_64-0.13.0/zig":             "binary",
+		"zig-linux-x86_64-0.13.0/lib/std/std.zig": "std",
+	})
+	if err := extractTarball(silentLogger{}, src, dest, -1, "auto", nil); err != nil {
+		t.Fatal(err)
+	}
+	checkExtracted(t, dest, map[string]string{
+		"zig":             "binary",
+		"lib/std/std.zig": "std",
+		"lib/old.zig":     "old",
+		destMarker:        "",
+	})
+}
+
+// checkExtracted fails t unless dest holds exactly files, which maps
+// slash separated paths to their contents.
+func checkExtracted(t *testing.T, dest string, files map[string]string) {
+	t.Helper()
+	got := map[string]string{}
+	err := filepath.WalkDir(dest, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dest, path)
+		got[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(files) {
+		t.Errorf("extracted %v, want %v", got, files)
+	}
+	for name, want := range files {
+		if content, ok := got[name]; !ok || content != want {
+			t.Errorf("%s = %q (present %v), want %q", name, content, ok, want)
+		}
+	}
+}
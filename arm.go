@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// armVariants returns the 32-bit ARM architecture names of the index that
+// run on a CPU of the given ARM architecture level, best first. Level 0
+// means unknown, which only gets the ARMv6 build: it runs everywhere,
+// while an ARMv7 build dies with SIGILL on an ARMv6 Raspberry Pi.
+func armVariants(level int) []string {
+	if level >= 7 {
+		return []string{"arm", "armv7a", "armv6kz"}
+	}
+	return []string{"armv6kz"}
+}
+
+// parseARMLevel reads the ARM architecture level from /proc/cpuinfo
+// contents. The model name, such as "ARMv6-compatible processor", wins
+// over the "CPU architecture" field, which reads 7 on the ARMv6 CPU of
+// the first Raspberry Pi. It returns 0 when neither says.
+func parseARMLevel(cpuinfo string) int {
+	model, arch := 0, 0
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "CPU architecture":
+			// "7", "8", or "AArch64" on some 64-bit kernels
+			if strings.EqualFold(value, "aarch64") {
+				arch = 8
+			} else if n, err := strconv.Atoi(value); err == nil {
+				arch = n
+			}
+		case "model name", "Processor":
+			if i := strings.Index(value, "ARMv"); i >= 0 && i+4 < len(value) {
+				if n, err := strconv.Atoi(value[i+4 : i+5]); err == nil {
+					model = n
+				}
+			}
+		}
+	}
+	if model > 0 {
+		return model
+	}
+	return arch
+}
+
+// hostARMLevel is the ARM architecture level of this host, read from
+// /proc/cpuinfo once. The error says why it could not be read.
+var hostARMLevel = sync.OnceValues(func() (int, error) {
+	data, err := os.ReadFile("/proc/cpuinfo")
+	return parseARMLevel(string(data)), err
+})
+
+// hostARMVariants is armVariants for this host.
+func hostARMVariants() []string {
+	level, _ := hostARMLevel()
+	return armVariants(level)
+}
+
+// armPlatformKey picks the first ARM variant of this host that entry has
+// a build of for os, falling back to the best one so the error names it.
+func armPlatformKey(log Logger, entry VersionEntry, os string) string {
+	level, err := hostARMLevel()
+	variants := armVariants(level)
+	switch {
+	case err != nil:
+		log.Debug("32-bit ARM host, cannot read /proc/cpuinfo (%v), trying %s", err, strings.Join(variants, ", "))
+	case level == 0:
+		log.Debug("32-bit ARM host, /proc/cpuinfo names no architecture level, trying %s", strings.Join(variants, ", "))
+	default:
+		log.Debug("32-bit ARM host, /proc/cpuinfo reports ARMv%d, trying %s", level, strings.Join(variants, ", "))
+	}
+	for _, arch := range variants {
+		if _, ok := entry.artifact(arch + "-" + os); ok {
+			return arch + "-" + os
+		}
+	}
+	return variants[0] + "-" + os
+}
+
+// pickARMVariant applies armPlatformKey when the platform is the host's
+// own and the host is 32-bit ARM, where the key alone can't tell which
+// build runs.
+func pickARMVariant(cfg Config, entry VersionEntry, platformKey string) string {
+	if runtime.GOARCH != "arm" || cfg.Target != "" || cfg.Arch != "" {
+		return platformKey
+	}
+	_, os := splitPlatformKey(platformKey)
+	return armPlatformKey(cfg.log(), entry, os)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseARMLevel(t *testing.T) {
+	tests := map[string]int{
+		// CPU architecture reads 7 on the ARMv6 CPU of the first Pi
+		"pi1-armv6.txt":        6,
+		"pi3-armv7.txt":        7,
+		"pi4-arm64-kernel.txt": 8,
+		"old-kernel-armv7.txt": 7,
+		"aarch64-field.txt":    8,
+	}
+	for name, want := range tests {
+		data, err := os.ReadFile(filepath.Join("testdata", "cpuinfo", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parseARMLevel(string(data)); got != want {
+			t.Errorf("parseARMLevel(%s) = %d, want %d", name, got, want)
+		}
+	}
+	if got := parseARMLevel(""); got != 0 {
+		t.Errorf("parseARMLevel of nothing = %d, want 0", got)
+	}
+}
+
+func TestARMVariants(t *testing.T) {
+	tests := map[int][]string{
+		0: {"armv6kz"},
+		6: {"armv6kz"},
+		7: {"arm", "armv7a", "armv6kz"},
+		8: {"arm", "armv7a", "armv6kz"},
+	}
+	for level, want := range tests {
+		if got := armVariants(level); !reflect.DeepEqual(got, want) {
+			t.Errorf("armVariants(%d) = %v, want %v", level, got, want)
+		}
+	}
+}
+
+func TestARMModifier(t *testing.T) {
+	h := hostInfo{GOOS: "linux", GOARCH: "arm", ARMVariants: armVariants(7)}
+	key, notes, err := detectPlatform(h)
+	if err != nil || key != "arm-linux" {
+		t.Errorf("ARMv7 host maps to %s, %v; want arm-linux", key, err)
+	}
+	if len(notes) != 1 || notes[0].Rule != "arm" {
+		t.Errorf("notes %+v, want one from the arm rule", notes)
+	}
+
+	h.ARMVariants = armVariants(0)
+	if key, _, _ := detectPlatform(h); key != "armv6kz-linux" {
+		t.Errorf("unknown ARM level maps to %s, want armv6kz-linux", key)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// backup is a previous install moved aside by --backup-dir.
+type backup struct {
+	dir string
+	bin string
+	lib string
+	// versioned is the versioned directory libPath linked to, which the
+	// lib tree goes back to on restore. It is empty for older installs.
+	versioned string
+}
+
+// takeLib moves the lib tree of the install at libPath to dest, dropping
+// the link to it, and remembers where it lived. A link to a tree the
+// installer did not make is moved itself.
+func (b *backup) takeLib(libPath, dest string, move func(src, dest string) error) error {
+	src := managedInstall(libPath)
+	if err := move(src, dest); err != nil {
+		return err
+	}
+	b.lib = dest
+	if src != libPath {
+		b.versioned = src
+		os.Remove(libPath)
+	}
+	return nil
+}
+
+// putLib moves the lib tree back to where takeLib found it.
+func (b *backup) putLib(libPath string, move func(src, dest string) error) error {
+	removeInstall(libPath)
+	if b.versioned == "" {
+		return move(b.lib, libPath)
+	}
+	os.RemoveAll(b.versioned)
+	if err := move(b.lib, b.versioned); err != nil {
+		return err
+	}
+	return linkInstall(libPath, b.versioned)
+}
+
+// backupInstall moves the install at binPath and libPath into a new
+// timestamped directory below backupDir. It returns nil when there is
+// nothing to back up.
+func backupInstall(backupDir, binPath, libPath string) (*backup, error) {
+	_, binErr := os.Lstat(binPath)
+	_, libErr := os.Lstat(libPath)
+	if binErr != nil && libErr != nil {
+		return nil, nil
+	}
+
+	name := now().UTC().Format("20060102T150405Z")
+	if m, err := readManifest(manifestPath(libPath)); err == nil {
+		name += "-" + m.Version
+	}
+	b := &backup{dir: filepath.Join(backupDir, name)}
+
+	if binErr == nil {
+		b.bin = filepath.Join(b.dir, "bin", filepath.Base(binPath))
+		if err := ensureDirectoryExists(filepath.Dir(b.bin)); err != nil {
+			return nil, err
+		}
+		if err := moveTree(binPath, b.bin); err != nil {
+			return nil, err
+		}
+	}
+	if libErr == nil {
+		b.lib = filepath.Join(b.dir, "lib", filepath.Base(libPath))
+		if err := ensureDirectoryExists(filepath.Dir(b.lib)); err != nil {
+			return nil, err
+		}
+		if err := b.takeLib(libPath, b.lib, moveTree); err != nil {
+			// Put the binary back so the old install stays usable
+			if b.bin != "" {
+				moveTree(b.bin, binPath)
+			}
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// oldSuffix marks the previous install kept next to the new one by
+// --keep-old.
+const oldSuffix = ".old"
+
+// keepOld renames the install at binPath and libPath to the same paths
+// with oldSuffix, replacing the install kept last time. It returns nil
+// when there is nothing to keep.
+func keepOld(binPath, libPath string) (*backup, error) {
+	_, binErr := os.Lstat(binPath)
+	_, libErr := os.Lstat(libPath)
+	if binErr != nil && libErr != nil {
+		return nil, nil
+	}
+
+	b := &backup{}
+	if libErr == nil {
+		os.RemoveAll(libPath + oldSuffix)
+		if err := b.takeLib(libPath, libPath+oldSuffix, os.Rename); err != nil {
+			return nil, err
+		}
+	}
+	if binErr == nil {
+		b.bin = binPath + oldSuffix
+		os.RemoveAll(b.bin)
+		if err := moveBinary(binPath, b.bin, libPath, b.lib); err != nil {
+			if b.lib != "" {
+				b.putLib(libPath, os.Rename)
+			}
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// moveBinary renames bin to dest. A symlink into libFrom, as --bin-name
+// installs have, is recreated pointing into libTo instead, so it keeps
+// following the lib directory it belongs to.
+func moveBinary(bin, dest, libFrom, libTo string) error {
+	link, err := os.Readlink(bin)
+	if err != nil || libTo == "" {
+		return os.Rename(bin, dest)
+	}
+	resolved := link
+	if !filepath.IsAbs(link) {
+		resolved = filepath.Join(filepath.Dir(bin), link)
+	}
+	if filepath.Dir(resolved) != filepath.Clean(libFrom) {
+		return os.Rename(bin, dest)
+	}
+	target := filepath.Join(filepath.Dir(filepath.Dir(link)), filepath.Base(libTo), filepath.Base(link))
+	if err := os.Symlink(target, dest); err != nil {
+		return err
+	}
+	return os.Remove(bin)
+}
+
+// restore moves the backed up install back into place, replacing whatever
+// a failed install left behind.
+func (b *backup) restore(binPath, libPath string) error {
+	if b.dir == "" {
+		// Kept by --keep-old right next to the install
+		if b.lib != "" {
+			if err := b.putLib(libPath, os.Rename); err != nil {
+				return err
+			}
+		}
+		if b.bin != "" {
+			os.RemoveAll(binPath)
+			return moveBinary(b.bin, binPath, b.lib, libPath)
+		}
+		return nil
+	}
+	if b.bin != "" {
+		os.RemoveAll(binPath)
+		if err := moveTree(b.bin, binPath); err != nil {
+			return err
+		}
+	}
+	if b.lib != "" {
+		if err := b.putLib(libPath, moveTree); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(b.dir)
+}
+
+// moveTree renames src to dest, falling back to copy and delete when they
+// are on different filesystems.
+func moveTree(src, dest string) error {
+	err := os.Rename(src, dest)
+	if err == nil {
+		return nil
+	}
+	if copyErr := copyTree(src, dest); copyErr != nil {
+		os.RemoveAll(dest)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies a file or directory tree, keeping modes and symlinks.
+func copyTree(src, dest string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
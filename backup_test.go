@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeBinary writes a stand-in zig binary to path.
+func fakeBinary(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeepOldRestoresVersionedInstall(t *testing.T) {
+	root := t.TempDir()
+	binPath, libDir := filepath.Join(root, "bin", "zig"), filepath.Join(root, "lib")
+	libPath := filepath.Join(libDir, "zig")
+	dir := fakeInstall(t, libDir, "zig", "0.13.0")
+	fakeBinary(t, binPath, "0.13.0")
+
+	b, err := keepOld(binPath, libPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(libPath); !os.IsNotExist(err) {
+		t.Error("the link is still in place")
+	}
+	if m, err := readManifest(manifestPath(libPath + oldSuffix)); err != nil || m.Version != "0.13.0" {
+		t.Errorf("kept install: %+v, %v", m, err)
+	}
+
+	// A failed install of the same version left its directory behind
+	os.MkdirAll(dir, 0755)
+	if err := b.restore(binPath, libPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolveInstall(libPath); got != dir {
+		t.Errorf("restored to %s, want %s", got, dir)
+	}
+	if m, err := readManifest(manifestPath(libPath)); err != nil || m.Version != "0.13.0" {
+		t.Errorf("restored install: %+v, %v", m, err)
+	}
+	if data, err := os.ReadFile(binPath); err != nil || string(data) != "0.13.0" {
+		t.Errorf("restored binary: %q, %v", data, err)
+	}
+}
+
+func TestBackupInstallRestoresVersionedInstall(t *testing.T) {
+	root := t.TempDir()
+	binPath, libDir := filepath.Join(root, "bin", "zig"), filepath.Join(root, "lib")
+	libPath := filepath.Join(libDir, "zig")
+	dir := fakeInstall(t, libDir, "zig", "0.12.0")
+	fakeBinary(t, binPath, "0.12.0")
+
+	b, err := backupInstall(filepath.Join(root, "backups"), binPath, libPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{binPath, libPath, dir} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not moved to the backup", path)
+		}
+	}
+	if m, err := readManifest(manifestPath(b.lib)); err != nil || m.Version != "0.12.0" {
+		t.Errorf("backed up install: %+v, %v", m, err)
+	}
+
+	// What a failed install of another version left behind
+	fakeInstall(t, libDir, "zig", "0.13.0")
+	if err := b.restore(binPath, libPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolveInstall(libPath); got != dir {
+		t.Errorf("restored to %s, want %s", got, dir)
+	}
+	if _, err := os.Stat(versionDir(libDir, "zig", "0.13.0")); !os.IsNotExist(err) {
+		t.Error("the failed install was left behind")
+	}
+	if _, err := os.Stat(b.dir); !os.IsNotExist(err) {
+		t.Error("the backup directory was left behind")
+	}
+}
+
+func TestKeepOldPlainInstall(t *testing.T) {
+	root := t.TempDir()
+	binPath, libPath := filepath.Join(root, "bin", "zig"), filepath.Join(root, "lib", "zig")
+	fakeBinary(t, binPath, "0.11.0")
+	if err := os.MkdirAll(libPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := keepOld(binPath, libPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.versioned != "" {
+		t.Errorf("a plain install was taken for a versioned one in %s", b.versioned)
+	}
+	if err := b.restore(binPath, libPath); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(libPath); err != nil || !info.IsDir() {
+		t.Errorf("the plain install did not come back as a directory: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// indexCacheEntry is the metadata stored next to a cached index body.
+type indexCacheEntry struct {
+	URL       string    `json:"url"`
+	FetchedAt time.Time `json:"fetched_at"`
+	ETag      string    `json:"etag,omitempty"`
+	Size      int64     `json:"size"`
+	// FinalURL is where the index was served from after redirects.
+	FinalURL string `json:"final_url,omitempty"`
+}
+
+// indexCacheDir holds one cached body and metadata file per index URL.
+func indexCacheDir(cfg Config) string {
+	return filepath.Join(cfg.CacheDir, "indexes")
+}
+
+// indexCachePaths returns where the body and metadata of url are cached,
+// named after a hash of the URL so different sources never collide.
+func indexCachePaths(cfg Config, url string) (body, meta string) {
+	sum := sha256.Sum256([]byte(url))
+	base := filepath.Join(indexCacheDir(cfg), hex.EncodeToString(sum[:])[:16])
+	return base + ".json", base + ".meta.json"
+}
+
+// readIndexCache returns the cached body and metadata of url.
+func readIndexCache(cfg Config, url string) ([]byte, indexCacheEntry, error) {
+	var entry indexCacheEntry
+	bodyPath, metaPath := indexCachePaths(cfg, url)
+	meta, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, entry, err
+	}
+	if err := json.Unmarshal(meta, &entry); err != nil {
+		return nil, entry, err
+	}
+	body, err := os.ReadFile(bodyPath)
+	return body, entry, err
+}
+
+func writeIndexCache(cfg Config, url string, body []byte, entry indexCacheEntry) error {
+	bodyPath, metaPath := indexCachePaths(cfg, url)
+	meta, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAll(bodyPath, body); err != nil {
+		return err
+	}
+	return writeFileAll(metaPath, append(meta, '\n'))
+}
+
+func removeIndexCache(cfg Config, url string) {
+	bodyPath, metaPath := indexCachePaths(cfg, url)
+	os.Remove(bodyPath)
+	os.Remove(metaPath)
+}
+
+// listIndexCache returns the metadata of every cached index, most recently
+// fetched first.
+func listIndexCache(cfg Config) ([]indexCacheEntry, error) {
+	matches, err := filepath.Glob(filepath.Join(indexCacheDir(cfg), "*.meta.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []indexCacheEntry
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var e indexCacheEntry
+		if json.Unmarshal(data, &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].FetchedAt.After(entries[j].FetchedAt) })
+	return entries, nil
+}
+
+// fetchIndexCached fetches the index at url, or at each URL of a comma
+// separated list. Several indexes are merged with earlier ones winning on
+// conflicting keys, and every entry remembers the index it came from. Only
+// the first index is required; the others degrade to a warning when they
+// cannot be fetched.
+func fetchIndexCached(cfg Config, client *http.Client, url string) (Index, error) {
+	index, _, err := fetchIndexSources(cfg, client, url)
+	return index, err
+}
+
+// fetchIndexSources is fetchIndexCached that also returns the comma
+// separated URLs the indexes came from: each requested URL, followed by
+// where it was redirected to. Tarball hosts are checked against these.
+// Relative tarball paths are resolved against where each index was
+// fetched from, after redirects.
+func fetchIndexSources(cfg Config, client *http.Client, url string) (Index, string, error) {
+	urls := splitList(url)
+	if len(urls) <= 1 {
+		index, final, err := fetchOneIndexCached(cfg, client, url)
+		if err == nil {
+			err = index.resolveTarballs(finalOr(final, url))
+		}
+		return index, withFinalURL(url, final), err
+	}
+
+	merged := make(Index)
+	var sources []string
+	for i, u := range urls {
+		index, final, err := fetchOneIndexCached(cfg, client, u)
+		if err == nil {
+			err = index.resolveTarballs(finalOr(final, u))
+		}
+		if err != nil {
+			if i == 0 {
+				return nil, "", err
+			}
+			cfg.log().Warning("skipping index %s: %v", u, err)
+			continue
+		}
+		sources = append(sources, withFinalURL(u, final))
+		for key, entry := range index {
+			if _, ok := merged[key]; ok {
+				continue
+			}
+			entry.Origin = u
+			merged[key] = entry
+		}
+	}
+	return merged, strings.Join(sources, ","), nil
+}
+
+// finalOr returns where url was redirected to, or url.
+func finalOr(final, url string) string {
+	if final == "" {
+		return url
+	}
+	return final
+}
+
+// withFinalURL appends final to url when a redirect changed it.
+func withFinalURL(url, final string) string {
+	if final == "" || final == url {
+		return url
+	}
+	return url + "," + final
+}
+
+// fetchOneIndexCached fetches the index at url through the on-disk cache. A
+// cache entry younger than cfg.CacheTTL is used without touching the
+// network, otherwise the index is revalidated with its ETag. Any cache
+// entry is the fallback when the fetch fails, and entries that no longer
+// parse are dropped.
+func fetchOneIndexCached(cfg Config, client *http.Client, url string) (Index, string, error) {
+	parse, err := indexParserFor(cfg.IndexFormat)
+	if err != nil {
+		return nil, "", err
+	}
+	if path, ok := strings.CutPrefix(url, "file://"); ok {
+		return readIndexFile(filepath.FromSlash(path), parse)
+	}
+	// Checked up front so an index once fetched over HTTP isn't used from
+	// the cache either
+	if cfg.RequireHTTPS && strings.HasPrefix(url, "http://") {
+		return nil, "", newError(kindUsage, "index URL %s is plain HTTP, refused by --require-https", url)
+	}
+
+	cached, entry, cacheErr := readIndexCache(cfg, url)
+	var cachedIndex Index
+	if cacheErr == nil {
+		if cachedIndex, cacheErr = parse(cached); cacheErr != nil {
+			cfg.log().Warning("discarding corrupted cache entry for %s: %v", url, cacheErr)
+			removeIndexCache(cfg, url)
+		}
+	}
+	if cacheErr == nil && cfg.CacheTTL > 0 && time.Since(entry.FetchedAt) < cfg.CacheTTL {
+		return cachedIndex, entry.FinalURL, nil
+	}
+
+	etag := ""
+	if cacheErr == nil {
+		etag = entry.ETag
+	}
+	resp, err := fetchIndexData(cfg.log(), client, url, etag, cfg.IndexTimeout)
+	if err != nil {
+		if cacheErr != nil {
+			return nil, "", err
+		}
+		cfg.log().Warning("%v, using index cached at %s", err, entry.FetchedAt.Local().Format("2006-01-02 15:04"))
+		return cachedIndex, entry.FinalURL, nil
+	}
+	data := resp.Data
+	if data == nil {
+		// Not modified since it was cached
+		data = cached
+	} else if cachedIndex, err = parse(data); err != nil {
+		return nil, "", err
+	}
+
+	entry = indexCacheEntry{URL: url, FetchedAt: time.Now().UTC(), ETag: resp.ETag, Size: int64(len(data))}
+	if resp.URL != url {
+		entry.FinalURL = resp.URL
+	}
+	if err := writeIndexCache(cfg, url, data, entry); err != nil {
+		cfg.log().Warning("failed to cache index: %v", err)
+	}
+	return cachedIndex, entry.FinalURL, nil
+}
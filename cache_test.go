@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheListDeterministic(t *testing.T) {
+	saved := deterministic
+	t.Cleanup(func() { deterministic = saved })
+	cfg := Config{CacheDir: t.TempDir()}
+	fetched := time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC)
+	entry := indexCacheEntry{URL: "https://ziglang.org/download/index.json", FetchedAt: fetched, ETag: `"abc"`, Size: 1024}
+	if err := writeIndexCache(cfg, entry.URL, []byte(testIndex), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(args ...string) string {
+		return string(captureOutput(t, &os.Stdout, func() {
+			var log Logger = silentLogger{}
+			if err := runCache(&log, append([]string{"list", "-indexes", "-cache-dir", cfg.CacheDir}, args...)); err != nil {
+				t.Fatal(err)
+			}
+		}))
+	}
+	if got := list("-deterministic"); !strings.Contains(got, "2024-06-08T12:00:00Z") {
+		t.Errorf("deterministic listing %q lacks the fetch time", got)
+	}
+	if got := list(); strings.Contains(got, "2024-06-08T12:00:00Z") {
+		t.Errorf("listing %q does not give the age", got)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cachedTarball is a tarball kept in the cache directory.
+type cachedTarball struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// cacheListing is the --json shape of cache list.
+type cacheListing struct {
+	Indexes  []indexCacheEntry `json:"indexes"`
+	Tarballs []cachedTarball   `json:"tarballs,omitempty"`
+}
+
+func runCache(log *Logger, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return newError(kindUsage, "usage: cache list [--indexes]")
+	}
+
+	var cfg Config
+	var indexesOnly bool
+	fs := newCommandFlags("cache list", "cache list [flags]", &cfg)
+	fs.BoolVar(&indexesOnly, "indexes", false, "Only list cached indexes")
+	if _, err := parseCommand(log, fs, &cfg, args[1:]); err != nil {
+		return err
+	}
+
+	var out cacheListing
+	var err error
+	if out.Indexes, err = listIndexCache(cfg); err != nil {
+		return err
+	}
+	if !indexesOnly {
+		matches, _ := filepath.Glob(filepath.Join(cfg.CacheDir, "*.tar.xz"))
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil {
+				out.Tarballs = append(out.Tarballs, cachedTarball{Name: filepath.Base(path), Size: info.Size()})
+			}
+		}
+	}
+
+	if cfg.JSON {
+		if out.Indexes == nil {
+			out.Indexes = []indexCacheEntry{}
+		}
+		return printJSON(out)
+	}
+	if len(out.Indexes) == 0 && len(out.Tarballs) == 0 {
+		cfg.log().Info("cache in %s is empty", cfg.CacheDir)
+		return nil
+	}
+
+	for _, e := range out.Indexes {
+		// The age changes from run to run, the fetch time does not
+		age := time.Since(e.FetchedAt).Round(time.Second).String()
+		if deterministic {
+			age = e.FetchedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Printf("%-10s %9s  %-20s %s\n", formatBytes(e.Size), age, dashIfEmpty(e.ETag), e.URL)
+	}
+	for _, t := range out.Tarballs {
+		fmt.Printf("%-10s %s\n", formatBytes(t.Size), t.Name)
+	}
+	return nil
+}
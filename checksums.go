@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// maxChecksumFileSize caps how much of a checksum list is read; lists for
+// every artifact of a release are a few kilobytes.
+const maxChecksumFileSize = 1 << 20
+
+var (
+	// bsdChecksumLine is the BSD/OpenSSL form "SHA256 (<file>) = <hex>".
+	bsdChecksumLine = regexp.MustCompile(`^SHA2?-?256 \((.+)\) ?= ?([0-9a-fA-F]{64})$`)
+	// gnuChecksumLine is the coreutils form "<hex>  <file>", with a '*'
+	// before the name in binary mode.
+	gnuChecksumLine = regexp.MustCompile(`^\\?([0-9a-fA-F]{64}) [ *]?(.+)$`)
+	bareChecksum    = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+)
+
+// fetchChecksum downloads a checksum file and returns the SHA-256 it lists
+// for name.
+func fetchChecksum(client *http.Client, url, name string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxChecksumFileSize))
+	if err != nil {
+		return "", err
+	}
+	sum, err := checksumFor(string(data), name)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", url, err)
+	}
+	return sum, nil
+}
+
+// checksumFor picks the SHA-256 of name out of a checksum list in GNU or
+// BSD format. Names are compared without their directory. A file holding
+// only a bare digest applies to whatever file it sits next to.
+func checksumFor(list, name string) (string, error) {
+	var listed []string
+	var bare []string
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var file, sum string
+		if m := bsdChecksumLine.FindStringSubmatch(line); m != nil {
+			file, sum = m[1], m[2]
+		} else if m := gnuChecksumLine.FindStringSubmatch(line); m != nil {
+			file, sum = m[2], m[1]
+		} else if bareChecksum.MatchString(line) {
+			bare = append(bare, line)
+			continue
+		} else {
+			continue
+		}
+
+		file = path.Base(strings.TrimSpace(file))
+		if file == name {
+			return strings.ToLower(sum), nil
+		}
+		listed = append(listed, file)
+	}
+
+	if len(listed) == 0 && len(bare) == 1 {
+		return strings.ToLower(bare[0]), nil
+	}
+	if len(listed) == 0 {
+		return "", fmt.Errorf(`no SHA-256 checksums found (expected "<hex>  <file>" or "SHA256 (<file>) = <hex>" lines)`)
+	}
+	return "", fmt.Errorf("no checksum for %s (lists: %s)", name, strings.Join(listed, ", "))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+func runCheckUpdate(log *Logger, args []string) error {
+	var cfg Config
+	fs := newCommandFlags("check-update", "check-update [flags]", &cfg)
+	// The exit code is the result, so --quiet silences errors too
+	if _, err := parseCommand(log, fs, &cfg, args, withQuietLevel(levelSilent)); err != nil {
+		return err
+	}
+
+	installed, err := readManifest(manifestPath(filepath.Join(cfg.rooted(cfg.LibDir), cfg.BinName)))
+	requested := cfg.Version
+	current := ""
+	if err == nil {
+		requested = installed.channel()
+		current = installed.Version
+	}
+
+	index, err := loadIndex(cfg, requested)
+	if err != nil {
+		return err
+	}
+	latest, err := resolveVersion(index, requested, cfg.Prerelease)
+	if err != nil {
+		return err
+	}
+	if v := index[latest].Version; v != "" {
+		latest = v
+	}
+
+	switch {
+	case current == "":
+		if !cfg.Quiet {
+			fmt.Printf("not installed → %s\n", latest)
+		}
+		return exitCode(exitUpdateAvailable)
+	case isNewerVersion(latest, current):
+		if !cfg.Quiet {
+			fmt.Printf("%s → %s\n", current, latest)
+		}
+		return exitCode(exitUpdateAvailable)
+	}
+	if !cfg.Quiet {
+		fmt.Printf("%s is up to date\n", current)
+	}
+	return nil
+}
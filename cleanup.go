@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// destMarker is written into the extraction directory so later runs know
+// it is safe to remove.
+const destMarker = ".zig-installer-dest"
+
+// ownsDir reports whether dir was created by zig-installer or is empty,
+// i.e. whether removing it cannot destroy anything of the user's.
+func ownsDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, destMarker)); err == nil {
+		return true
+	}
+	entries, err := os.ReadDir(dir)
+	return err == nil && len(entries) == 0
+}
+
+// removeDest removes the extraction directory, but only if zig-installer
+// owns it. A --dest pointing at a directory with other contents is left
+// alone.
+func removeDest(dest string) error {
+	if _, err := os.Lstat(dest); os.IsNotExist(err) {
+		return nil
+	}
+	if !ownsDir(dest) {
+		return newError(kindFilesystem, "refusing to remove %s: it was not created by zig-installer (choose an empty or new --dest)", dest)
+	}
+	return os.RemoveAll(dest)
+}
+
+// prepareDest sets up the extraction directory and marks it as ours. With
+// clean set, leftovers of a previous run are removed first.
+func prepareDest(dest string, clean bool) error {
+	if _, err := os.Lstat(dest); err == nil {
+		if !ownsDir(dest) {
+			return newError(kindFilesystem, "%s exists and was not created by zig-installer (choose an empty or new --dest)", dest)
+		}
+		if clean {
+			if err := os.RemoveAll(dest); err != nil {
+				return newError(kindFilesystem, "failed to clean %s: %v", dest, err)
+			}
+		}
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return newError(kindFilesystem, "failed to create %s: %v", dest, err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, destMarker), nil, 0644); err != nil {
+		return newError(kindFilesystem, "failed to mark %s: %v", dest, err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// deterministic is set by --deterministic. It turns off progress output
+// and pins every timestamp the installer prints or records, so logs and
+// golden files can be compared byte for byte.
+var deterministic bool
+
+// now returns the current time, or a fixed one in deterministic mode:
+// SOURCE_DATE_EPOCH when set, the Unix epoch otherwise.
+func now() time.Time {
+	if !deterministic {
+		return time.Now()
+	}
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC()
+	}
+	return time.Unix(0, 0).UTC()
+}
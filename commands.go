@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// commands maps subcommand names to their implementation. Anything else
+// on the command line is handled by the default install flow. It is filled
+// in by init because some commands refer back to it (e.g. completions).
+// A command stores the Logger it configured from its flags in *log, so
+// a failure is reported the way the run was asked to log.
+var commands map[string]func(log *Logger, args []string) error
+
+func init() {
+	commands = map[string]func(log *Logger, args []string) error{
+		"cache":        runCache,
+		"check-update": runCheckUpdate,
+		"diff":         runDiff,
+		"info":         runInfo,
+		"install":      runInstallCommand,
+		"list":         runList,
+		"notes":        runNotes,
+		"outdated":     runOutdated,
+		"status":       runStatus,
+		"upgrade":      runUpgrade,
+		"verify":       runVerify,
+		"zls":          runZLS,
+	}
+}
+
+// commandNames returns the subcommand names, sorted.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newCommandFlags returns a flag set for a subcommand that already knows
+// the shared flags.
+func newCommandFlags(name, usage string, cfg *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	registerFlags(fs, cfg)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s\n\nFlags:\n", os.Args[0], usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseCommand parses the arguments of a subcommand, applies the shared
+// settings in cfg, and returns the positional arguments. The logger built
+// from the flags with opts goes to cfg.Logger and *log.
+func parseCommand(log *Logger, fs *flag.FlagSet, cfg *Config, args []string, opts ...loggerOption) ([]string, error) {
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := setupLogger(log, cfg, fs, opts...); err != nil {
+		return nil, err
+	}
+	return positional, nil
+}
+
+// setupLogger builds the logger for the parsed flags in cfg and stores it
+// in cfg.Logger and *log. fs holds the flags, for the --log-file header.
+func setupLogger(log *Logger, cfg *Config, fs *flag.FlagSet, opts ...loggerOption) error {
+	deterministic = cfg.Deterministic
+	// A log file that cannot be opened is reported in the chosen format
+	*log = newLogger(*cfg, opts...)
+	f, err := openLogFile(*cfg, fs)
+	if err != nil {
+		return err
+	}
+	cfg.Logger = newLogger(*cfg, append(opts, withLogFile(f))...)
+	*log = cfg.Logger
+	return nil
+}
+
+// parseArgs parses fs while allowing flags and positional arguments to be
+// mixed, and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// loadIndex fetches the index that serves the given version.
+func loadIndex(cfg Config, version string) (Index, error) {
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	url, err := indexURLFor(cfg, version)
+	if err != nil {
+		return nil, err
+	}
+	return fetchIndexCached(cfg, client, url)
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// formatBytes renders n as a human readable size.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
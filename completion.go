@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// flagNames returns every registered flag, sorted, for use in completions.
+func flagNames() []string {
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	sort.Strings(names)
+	return names
+}
+
+// completionScript renders the completion script for the given shell.
+func completionScript(shell string) (string, error) {
+	names := flagNames()
+	var b strings.Builder
+
+	switch shell {
+	case "bash":
+		opts := commandNames()
+		for _, n := range names {
+			opts = append(opts, "--"+n)
+		}
+		fmt.Fprintf(&b, "# bash completion for zig-installer\n")
+		fmt.Fprintf(&b, "_zig_installer() {\n")
+		fmt.Fprintf(&b, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		fmt.Fprintf(&b, "    COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(opts, " "))
+		fmt.Fprintf(&b, "}\n")
+		fmt.Fprintf(&b, "complete -o default -F _zig_installer zig-installer\n")
+	case "zsh":
+		fmt.Fprintf(&b, "#compdef zig-installer\n\n")
+		fmt.Fprintf(&b, "_arguments \\\n")
+		flag.VisitAll(func(f *flag.Flag) {
+			usage := strings.NewReplacer("[", "\\[", "]", "\\]", "'", "'\\''").Replace(f.Usage)
+			fmt.Fprintf(&b, "  '--%s=[%s]' \\\n", f.Name, usage)
+		})
+		fmt.Fprintf(&b, "  '*:file:_files'\n")
+	case "fish":
+		fmt.Fprintf(&b, "# fish completion for zig-installer\n")
+		flag.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(&b, "complete -c zig-installer -l %s -d %q\n", f.Name, f.Usage)
+		})
+	default:
+		return "", fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", shell)
+	}
+	return b.String(), nil
+}
+
+// completionPath returns where the completion script for shell should be
+// installed, preferring the system location when running as root.
+func completionPath(shell string) (string, error) {
+	system := os.Geteuid() == 0
+	dataHome := getEnv("XDG_DATA_HOME", "")
+	configHome := getEnv("XDG_CONFIG_HOME", "")
+	if !system {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		if configHome == "" {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+
+	switch shell {
+	case "bash":
+		if system {
+			return filepath.Join(systemSharePrefix(), "bash-completion", "completions", "zig-installer"), nil
+		}
+		return filepath.Join(dataHome, "bash-completion", "completions", "zig-installer"), nil
+	case "zsh":
+		if system {
+			return "/usr/local/share/zsh/site-functions/_zig-installer", nil
+		}
+		return filepath.Join(dataHome, "zsh", "site-functions", "_zig-installer"), nil
+	case "fish":
+		if system {
+			return filepath.Join(systemSharePrefix(), "fish", "vendor_completions.d", "zig-installer.fish"), nil
+		}
+		return filepath.Join(configHome, "fish", "completions", "zig-installer.fish"), nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", shell)
+}
+
+// detectShell returns the name of the user's login shell.
+func detectShell() string {
+	return filepath.Base(getEnv("SHELL", "bash"))
+}
+
+// manPage renders a minimal roff man page from the registered flags.
+func manPage() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH ZIG-INSTALLER 1 %q \"zig-installer %s\"\n", now().Format("2006-01-02"), installerVersion())
+	fmt.Fprintf(&b, ".SH NAME\nzig-installer \\- download, verify and install the Zig compiler\n")
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n.B zig-installer\n[\\fIOPTIONS\\fR]\n")
+	fmt.Fprintf(&b, ".SH OPTIONS\n")
+	flag.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(&b, ".TP\n.BR \\-\\-%s\n%s", strings.ReplaceAll(f.Name, "-", "\\-"), f.Usage)
+		if f.DefValue != "" {
+			fmt.Fprintf(&b, " (default: %s)", f.DefValue)
+		}
+		fmt.Fprintf(&b, "\n")
+	})
+	return b.String()
+}
+
+// manPagePath returns where the man page should be installed.
+func manPagePath() (string, error) {
+	if os.Geteuid() == 0 {
+		return "/usr/local/share/man/man1/zig-installer.1", nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "man", "man1", "zig-installer.1"), nil
+}
+
+// installCompletions writes the completion script for the detected shell
+// and, if requested, the man page.
+func installCompletions(log Logger, withMan bool) error {
+	shell := detectShell()
+	script, err := completionScript(shell)
+	if err != nil {
+		return err
+	}
+	dest, err := completionPath(shell)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAll(dest, []byte(script)); err != nil {
+		return fmt.Errorf("failed to write %s completion: %v", shell, err)
+	}
+	log.Success("installed %s completion to %s", shell, dest)
+
+	if !withMan {
+		return nil
+	}
+	dest, err = manPagePath()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAll(dest, []byte(manPage())); err != nil {
+		return fmt.Errorf("failed to write man page: %v", err)
+	}
+	log.Success("installed man page to %s", dest)
+	return nil
+}
+
+// writeFileAll writes data to path, creating parent directories.
+func writeFileAll(path string, data []byte) error {
+	if err := ensureDirectoryExists(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
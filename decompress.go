@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// decompressor is an external program that can unpack an archive format
+// from stdin to stdout ahead of tar, using more than one core.
+type decompressor struct {
+	format string
+	args   []string
+}
+
+// decompressors are the programs --decompressor can name besides auto and
+// tar. pixz only decompresses in parallel what it compressed itself, xz
+// only multi-block files; both still take the work off tar.
+var decompressors = map[string]decompressor{
+	"pixz": {formatXZ, []string{"-d"}},
+	"xz":   {formatXZ, []string{"-d", "-c", "-T0"}},
+	"pigz": {formatGzip, []string{"-d", "-c"}},
+}
+
+// autoDecompressors are tried in order by --decompressor=auto, per format.
+var autoDecompressors = map[string][]string{
+	formatXZ:   {"pixz"},
+	formatGzip: {"pigz"},
+}
+
+// tarCompressionFlags tell tar how to decompress a format itself.
+var tarCompressionFlags = map[string]string{
+	formatXZ:   "-J",
+	formatGzip: "-z",
+}
+
+// checkDecompressor rejects a --decompressor value that names no known
+// or no installed program, before anything is downloaded.
+func checkDecompressor(choice string) error {
+	if choice == "auto" || choice == "tar" {
+		return nil
+	}
+	if _, ok := decompressors[choice]; ok {
+		if _, err := exec.LookPath(choice); err != nil {
+			return newError(kindNotFound, "missing dependency: %s (from --decompressor)", choice)
+		}
+		return nil
+	}
+	names := []string{"auto", "tar"}
+	for name := range decompressors {
+		names = append(names, name)
+	}
+	sort.Strings(names[2:])
+	return newError(kindUsage, "unknown --decompressor %q; valid values: %s", choice, strings.Join(names, ", "))
+}
+
+// pickDecompressor returns the program to decompress an archive of format
+// with, or "" to leave it to tar. auto takes a parallel one when it is
+// installed; a program named explicitly has to exist and fit the format.
+func pickDecompressor(choice, format string) (string, error) {
+	if err := checkDecompressor(choice); err != nil {
+		return "", err
+	}
+	switch choice {
+	case "tar":
+		return "", nil
+	case "auto":
+		for _, name := range autoDecompressors[format] {
+			if _, err := exec.LookPath(name); err == nil {
+				return name, nil
+			}
+		}
+		return "", nil
+	}
+	if decompressors[choice].format != format {
+		return "", newError(kindUsage, "--decompressor %s cannot unpack this archive; it is %s compressed", choice, dashIfEmpty(format))
+	}
+	return choice, nil
+}
+
+// runTar runs tar with args on the archive of format read from in, piping
+// it through the decompressor picked by choice first, or having tar
+// decompress it. It returns tar's stdout, the stderr of whichever failed,
+// and the error.
+func runTar(log Logger, in io.Reader, format, choice string, args ...string) ([]byte, []byte, error) {
+	program, err := pickDecompressor(choice, format)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	if program == "" {
+		if flag := tarCompressionFlags[format]; flag != "" {
+			args = append([]string{flag}, args...)
+		}
+		log.Debug("running tar %s", strings.Join(args, " "))
+		tar := exec.Command("tar", args...)
+		tar.Stdin, tar.Stdout, tar.Stderr = in, &stdout, &stderr
+		err := tar.Run()
+		return stdout.Bytes(), stderr.Bytes(), err
+	}
+
+	var decStderr bytes.Buffer
+	dec := exec.Command(program, decompressors[program].args...)
+	dec.Stdin, dec.Stderr = in, &decStderr
+	pipe, err := dec.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := dec.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start %s: %v", program, err)
+	}
+
+	log.Debug("running %s %s | tar %s", program, strings.Join(decompressors[program].args, " "), strings.Join(args, " "))
+	tar := exec.Command("tar", args...)
+	tar.Stdin, tar.Stdout, tar.Stderr = pipe, &stdout, &stderr
+	tarErr := tar.Run()
+	// A tar that stopped early would leave the decompressor blocked
+	pipe.Close()
+	decErr := dec.Wait()
+
+	switch {
+	case tarErr != nil:
+		// A corrupt archive shows in the decompressor's output first
+		return stdout.Bytes(), append(decStderr.Bytes(), stderr.Bytes()...), tarErr
+	case decErr != nil:
+		return stdout.Bytes(), decStderr.Bytes(), fmt.Errorf("%s: %v", program, decErr)
+	}
+	return stdout.Bytes(), stderr.Bytes(), nil
+}
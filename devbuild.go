@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+const defaultDevURLTemplate = "https://ziglang.org/builds/zig-{os}-{arch}-{version}.tar.xz"
+
+// isDevVersion reports whether v is a dev build such as
+// 0.14.0-dev.2290+abcdef.
+func isDevVersion(v string) bool {
+	parsed, err := parseVersion(v)
+	return err == nil && strings.HasPrefix(parsed.Pre, "dev.")
+}
+
+// devBuildURL fills the {os}, {arch} and {version} placeholders of the
+// template for a platform key such as x86_64-linux.
+func devBuildURL(template, platformKey, version string) string {
+	arch, os, _ := strings.Cut(platformKey, "-")
+	return strings.NewReplacer("{os}", os, "{arch}", arch, "{version}", version).Replace(template)
+}
+
+// devRelease builds a release for a dev version that has dropped out of
+// the index. The checksum comes from --expected-sha256, --checksum-url or,
+// failing those, from a .sha256 file published next to the tarball.
+func devRelease(cfg Config, client *http.Client, version, platformKey string) (release, error) {
+	url := devBuildURL(cfg.DevURLTemplate, platformKey, version)
+	if err := validateTarballURL(url, "", nil, true); err != nil {
+		return release{}, err
+	}
+	cfg.log().Info("%s is not in the index, trying %s", version, url)
+
+	shasum := strings.ToLower(cfg.ExpectedSHA256)
+	if shasum == "" {
+		var err error
+		list := cfg.ChecksumURL
+		if list == "" {
+			list = url + ".sha256"
+		}
+		shasum, err = fetchChecksum(client, list, path.Base(url))
+		if err != nil {
+			return release{}, newError(kindNotFound, "no checksum for dev build %s: %v; pass --expected-sha256 to install it anyway (old dev builds are eventually removed from ziglang.org/builds)", version, err)
+		}
+	}
+	return release{
+		Version:  version,
+		Platform: platformKey,
+		Artifact: Artifact{Tarball: url, Shasum: shasum},
+		devBuild: true,
+	}, nil
+}
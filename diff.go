@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diffSide is one version of the diff command.
+type diffSide struct {
+	Key       string     `json:"key"`
+	Version   string     `json:"version"`
+	Date      string     `json:"date,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+	Artifact  *Artifact  `json:"artifact,omitempty"`
+	Footprint *footprint `json:"footprint,omitempty"`
+}
+
+// footprint is what an install takes up on disk.
+type footprint struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// diffOutput is the --json shape of the diff command.
+type diffOutput struct {
+	Platform  string   `json:"platform"`
+	From      diffSide `json:"from"`
+	To        diffSide `json:"to"`
+	SizeDelta *int64   `json:"size_delta,omitempty"`
+}
+
+func runDiff(log *Logger, args []string) error {
+	var cfg Config
+	var platform string
+	flags := newCommandFlags("diff", "diff [flags] <version> <version>", &cfg)
+	flags.StringVar(&platform, "platform", getPlatformKey(), "Platform key to compare the artifacts of")
+	positional, err := parseCommand(log, flags, &cfg, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return newError(kindUsage, "diff needs exactly two versions, e.g. diff 0.12.1 0.13.0")
+	}
+
+	installed, err := listInstalled(cfg.rooted(cfg.LibDir))
+	if err != nil {
+		return err
+	}
+
+	out := diffOutput{Platform: platform}
+	for i, requested := range positional {
+		side, err := diffSideFor(cfg, requested, platform, installed)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			out.From = side
+		} else {
+			out.To = side
+		}
+	}
+	if out.From.Artifact != nil && out.To.Artifact != nil {
+		delta := out.To.Artifact.Size - out.From.Artifact.Size
+		out.SizeDelta = &delta
+	}
+
+	if cfg.JSON {
+		return printJSON(out)
+	}
+
+	size := func(s diffSide) string {
+		if s.Artifact == nil {
+			return "no " + platform + " build"
+		}
+		return formatBytes(s.Artifact.Size)
+	}
+	shasum := func(s diffSide) string {
+		if s.Artifact == nil {
+			return ""
+		}
+		return s.Artifact.Shasum
+	}
+	rows := [][3]string{
+		{"", out.From.Version, out.To.Version},
+		{"date", out.From.Date, out.To.Date},
+		{"notes", out.From.Notes, out.To.Notes},
+		{"size", size(out.From), size(out.To)},
+		{"shasum", shasum(out.From), shasum(out.To)},
+	}
+	if out.SizeDelta != nil {
+		rows = append(rows, [3]string{"size delta", "", signedBytes(*out.SizeDelta)})
+	}
+	if from, to := out.From.Footprint, out.To.Footprint; from != nil && to != nil {
+		files := func(f *footprint) string { return fmt.Sprintf("%d files, %s", f.Files, formatBytes(f.Bytes)) }
+		rows = append(rows,
+			[3]string{"installed", files(from), files(to)},
+			[3]string{"disk delta", "", fmt.Sprintf("%+d files, %s", to.Files-from.Files, signedBytes(to.Bytes-from.Bytes))})
+	}
+
+	width := 0
+	for _, r := range rows {
+		width = max(width, len(r[1]))
+	}
+	for _, r := range rows {
+		if r[1] == "" && r[2] == "" {
+			continue
+		}
+		name := r[0]
+		if name != "" {
+			name += ":"
+		}
+		from, to := r[1], r[2]
+		if !strings.HasSuffix(r[0], "delta") {
+			from, to = dashIfEmpty(from), dashIfEmpty(to)
+		}
+		fmt.Printf("  %-11s %-*s  %s\n", name, width, from, to)
+	}
+	return nil
+}
+
+// diffSideFor gathers the index metadata of one version and, if it is
+// installed, its footprint.
+func diffSideFor(cfg Config, requested, platform string, installed []Manifest) (diffSide, error) {
+	index, err := loadIndex(cfg, requested)
+	if err != nil {
+		return diffSide{}, err
+	}
+	key, err := resolveVersion(index, requested, cfg.Prerelease)
+	if err != nil {
+		return diffSide{}, err
+	}
+	entry := index[key]
+
+	side := diffSide{Key: key, Version: key, Date: entry.Date, Notes: entry.Notes}
+	if entry.Version != "" {
+		side.Version = entry.Version
+	}
+	if a, ok := entry.artifact(platform); ok {
+		side.Artifact = &a
+	}
+	for _, m := range installed {
+		if m.Version == side.Version {
+			if f, err := installFootprint(cfg, m); err == nil {
+				side.Footprint = &f
+			}
+			break
+		}
+	}
+	return side, nil
+}
+
+// installFootprint counts the files and bytes of an install.
+func installFootprint(cfg Config, m Manifest) (footprint, error) {
+	var f footprint
+	if info, err := os.Stat(cfg.rooted(m.BinPath)); err == nil {
+		f.Files++
+		f.Bytes += info.Size()
+	}
+	err := filepath.WalkDir(resolveInstall(cfg.rooted(m.LibPath)), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f.Files++
+		f.Bytes += info.Size()
+		return nil
+	})
+	return f, err
+}
+
+// signedBytes formats a size delta with its sign.
+func signedBytes(n int64) string {
+	if n < 0 {
+		return "-" + formatBytes(-n)
+	}
+	return "+" + formatBytes(n)
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// transferDigest is a digest of the response body announced in a header,
+// checked while downloading as an early corruption signal. The index's
+// sha256 remains the authoritative check.
+type transferDigest struct {
+	header string
+	algo   string
+	want   []byte
+}
+
+// digestAlgos are the algorithms transfer digests are checked for, by
+// their lowercase names in Digest and Content-Digest headers.
+var digestAlgos = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// responseDigest returns the strongest supported digest a response
+// announces in Content-Digest (RFC 9530), Digest (RFC 3230) or
+// Content-MD5, or nil. Bodies Go decompressed on the fly are skipped,
+// since the digest covers the compressed bytes.
+func responseDigest(resp *http.Response) *transferDigest {
+	if resp.Uncompressed {
+		return nil
+	}
+	var found []transferDigest
+	for _, v := range resp.Header.Values("Content-Digest") {
+		for _, item := range strings.Split(v, ",") {
+			algo, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+			// Structured field byte sequences are wrapped in colons
+			value = strings.Trim(value, ":")
+			if ok {
+				found = append(found, transferDigest{header: "Content-Digest", algo: strings.ToLower(algo), want: decodeBase64(value)})
+			}
+		}
+	}
+	for _, v := range resp.Header.Values("Digest") {
+		for _, item := range strings.Split(v, ",") {
+			algo, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if ok {
+				found = append(found, transferDigest{header: "Digest", algo: strings.ToLower(algo), want: decodeBase64(value)})
+			}
+		}
+	}
+	if v := resp.Header.Get("Content-MD5"); v != "" {
+		found = append(found, transferDigest{header: "Content-MD5", algo: "md5", want: decodeBase64(v)})
+	}
+
+	var best *transferDigest
+	for i, d := range found {
+		newHash, ok := digestAlgos[d.algo]
+		if !ok || d.want == nil || len(d.want) != newHash().Size() {
+			continue
+		}
+		if best == nil || newHash().Size() > digestAlgos[best.algo]().Size() {
+			best = &found[i]
+		}
+	}
+	return best
+}
+
+func decodeBase64(s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// newHash returns a hash for the digest's algorithm.
+func (d *transferDigest) newHash() hash.Hash {
+	return digestAlgos[d.algo]()
+}
+
+// check compares the digest with the hash of the received body.
+func (d *transferDigest) check(log Logger, url string, h hash.Hash) error {
+	got := h.Sum(nil)
+	if string(got) == string(d.want) {
+		log.Debug("%s %s of %s matches", d.header, d.algo, url)
+		return nil
+	}
+	return newError(kindChecksum, "download of %s was corrupted in transit: %s header has %s %s, received data has %s",
+		url, d.header, d.algo, hex.EncodeToString(d.want), hex.EncodeToString(got))
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// installPlan is what --dry-run reports an install would do, and its
+// --json shape.
+type installPlan struct {
+	OK       bool             `json:"ok"`
+	Status   string           `json:"status"`
+	Version  string           `json:"version"`
+	Platform string           `json:"platform"`
+	Download *plannedDownload `json:"download,omitempty"`
+	// Tarball is the download target, or the existing file that is
+	// reused when Download is nil.
+	Tarball   string `json:"tarball"`
+	ExtractTo string `json:"extract_to,omitempty"`
+	CopyTo    string `json:"copy_to,omitempty"`
+	Bin       string `json:"bin,omitempty"`
+	// Lib is the versioned directory the lib tree goes into, which
+	// <lib-dir>/<bin-name> is linked to.
+	Lib string `json:"lib,omitempty"`
+	// Remove lists existing files and directories that would be deleted.
+	Remove []string `json:"remove"`
+	// Keep lists where the previous install would be moved, see
+	// --keep-old and --backup-dir.
+	Keep      []string `json:"keep,omitempty"`
+	NeedsRoot bool     `json:"needs_root"`
+	// Problems would make the install fail; the plan is not viable.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// plannedDownload is the tarball --dry-run would download.
+type plannedDownload struct {
+	URL     string   `json:"url"`
+	Mirrors []string `json:"mirrors,omitempty"`
+	// Size is 0 when the index does not say.
+	Size   int64  `json:"size"`
+	Shasum string `json:"shasum"`
+}
+
+// planInstall works out what installing rel would change, touching
+// nothing. download says whether the tarball has to be fetched.
+func planInstall(cfg Config, rel release, download bool) installPlan {
+	p := installPlan{
+		Status:   "planned",
+		Version:  rel.Version,
+		Platform: rel.Platform,
+		Tarball:  cfg.TarDest,
+		Remove:   []string{},
+	}
+	if download {
+		p.Download = &plannedDownload{URL: rel.Artifact.Tarball, Size: rel.Artifact.Size, Shasum: rel.Artifact.Shasum}
+		for _, m := range splitList(cfg.Mirrors) {
+			p.Download.Mirrors = append(p.Download.Mirrors, strings.TrimSuffix(m, "/")+"/"+filepath.Base(rel.Artifact.Tarball))
+		}
+		p.addRemoval(cfg.TarDest)
+	}
+	writes := []string{filepath.Dir(cfg.TarDest)}
+
+	if cfg.InstallTarballTo != "" {
+		p.CopyTo = cfg.InstallTarballTo
+		writes = append(writes, cfg.InstallTarballTo)
+		p.finish(writes)
+		return p
+	}
+
+	p.ExtractTo = cfg.Dest
+	writes = append(writes, filepath.Dir(cfg.Dest))
+	if _, err := os.Lstat(cfg.Dest); err == nil {
+		if !ownsDir(cfg.Dest) {
+			p.Problems = append(p.Problems, fmt.Sprintf("%s exists and was not created by zig-installer", cfg.Dest))
+		} else if cfg.Clean {
+			p.addRemoval(cfg.Dest)
+		}
+	}
+	if cfg.DestOnly {
+		p.finish(writes)
+		return p
+	}
+
+	binDir, libDir := cfg.rooted(cfg.BinDir), cfg.rooted(cfg.LibDir)
+	p.Bin = filepath.Join(binDir, binaryFile(cfg.BinName, rel.Platform))
+	p.Lib = versionDir(libDir, cfg.BinName, rel.Version)
+	libPath := filepath.Join(libDir, cfg.BinName)
+	writes = append(writes, binDir, libDir)
+	if err := checkReplaceable(libPath); err != nil {
+		p.Problems = append(p.Problems, err.Error())
+	}
+	if err := checkVersionDir(p.Lib); err != nil {
+		p.Problems = append(p.Problems, err.Error())
+	}
+	// The versioned directory the current install lives in goes with it
+	current := managedInstall(libPath)
+	for _, kind := range []string{"bin", "lib"} {
+		path := p.Bin
+		if kind == "lib" {
+			path = libPath
+		}
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		switch {
+		case cfg.BackupDir != "":
+			p.Keep = append(p.Keep, filepath.Join(cfg.BackupDir, "<timestamp>", kind, filepath.Base(path)))
+			writes = append(writes, cfg.BackupDir)
+		case cfg.KeepOld:
+			p.addRemoval(path + oldSuffix)
+			p.Keep = append(p.Keep, path+oldSuffix)
+		default:
+			p.addRemoval(path)
+			if kind == "lib" && current != libPath {
+				p.addRemoval(current)
+			}
+		}
+	}
+	// Left behind by an install that did not finish
+	if p.Lib != current || cfg.BackupDir == "" && !cfg.KeepOld {
+		p.addRemoval(p.Lib)
+	}
+	p.finish(writes)
+	return p
+}
+
+// addRemoval lists path for removal if it exists.
+func (p *installPlan) addRemoval(path string) {
+	for _, listed := range p.Remove {
+		if listed == path {
+			return
+		}
+	}
+	if _, err := os.Lstat(path); err == nil {
+		p.Remove = append(p.Remove, path)
+	}
+}
+
+// finish decides whether the directories written to need root and
+// whether the plan is viable.
+func (p *installPlan) finish(writes []string) {
+	for _, dir := range writes {
+		if !writable(existingAncestor(dir)) {
+			p.NeedsRoot = true
+		}
+	}
+	p.OK = len(p.Problems) == 0
+}
+
+// existingAncestor returns path or the closest of its parents that
+// exists, which is where creating path would need write access.
+func existingAncestor(path string) string {
+	path, _ = filepath.Abs(path)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// printPlan reports the plan of --dry-run and exits with the filesystem
+// error code when it has problems.
+func printPlan(cfg Config, p installPlan) error {
+	if cfg.JSON {
+		if err := printJSON(p); err != nil {
+			return err
+		}
+	} else {
+		cfg.log().Info("dry run, nothing will be changed")
+		printField("version", p.Version)
+		printField("platform", p.Platform)
+		if p.Download != nil {
+			size := "unknown size"
+			if p.Download.Size > 0 {
+				size = formatBytes(p.Download.Size)
+			}
+			printField("download", fmt.Sprintf("%s (%s)", p.Download.URL, size))
+			for _, m := range p.Download.Mirrors {
+				printField("mirror", m)
+			}
+			printField("save to", p.Tarball)
+		} else {
+			printField("reuse", p.Tarball)
+		}
+		if p.ExtractTo != "" {
+			printField("extract", p.ExtractTo)
+		}
+		if p.CopyTo != "" {
+			printField("copy to", p.CopyTo)
+		}
+		if p.Bin != "" {
+			printField("binary", p.Bin)
+			printField("lib", p.Lib)
+		}
+		for _, path := range p.Remove {
+			printField("remove", path)
+		}
+		for _, path := range p.Keep {
+			printField("keep old", path)
+		}
+		sudo := "not needed"
+		if p.NeedsRoot {
+			sudo = "needed, the current user cannot write all target directories"
+		}
+		printField("sudo", sudo)
+		for _, problem := range p.Problems {
+			cfg.log().Error("%s", problem)
+		}
+	}
+	if !p.OK {
+		return exitCode(exitFilesystem)
+	}
+	return nil
+}
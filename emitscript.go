@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// downloadCommand is the shell command saving url to $tarball.
+func downloadCommand(url string) string {
+	if src, ok := parseSSHSource(url); ok {
+		port := ""
+		if src.port != "" {
+			port = " -P " + src.port
+		}
+		return fmt.Sprintf(`scp -B%s -- %s "$tarball"`, port, shellQuote(src.target()))
+	}
+	return fmt.Sprintf(`curl -fL -o "$tarball" %s`, shellQuote(url))
+}
+
+// installScript renders the install of rel as a POSIX shell script for
+// --emit-script: download, checksum, extract and move into place, with
+// the resolved URL and checksum baked in. It does the same as the
+// installer minus the manifest, backups and post-install checks.
+func installScript(cfg Config, rel release) string {
+	var b strings.Builder
+	w := func(format string, a ...interface{}) { fmt.Fprintf(&b, format+"\n", a...) }
+
+	binDir, libDir := cfg.rooted(cfg.BinDir), cfg.rooted(cfg.LibDir)
+	var urls []string
+	for _, m := range splitList(cfg.Mirrors) {
+		urls = append(urls, strings.TrimSuffix(m, "/")+"/"+path.Base(rel.Artifact.Tarball))
+	}
+	urls = append(urls, rel.Artifact.Tarball)
+
+	w("#!/bin/sh")
+	w("# Installs Zig %s for %s.", rel.Version, rel.Platform)
+	w("# Written by zig-installer %s with --emit-script; review it before running.", installerVersion())
+	w("# Unlike zig-installer it records no manifest, so status, verify and")
+	w("# outdated will not know about this install.")
+	w("set -eu")
+	w("")
+	w("sha256=%s", shellQuote(rel.Artifact.Shasum))
+	w("tarball=%s", shellQuote(cfg.TarDest))
+	w("dest=%s", shellQuote(cfg.Dest))
+	w("bin_path=%s", shellQuote(filepath.Join(binDir, binaryFile(cfg.BinName, rel.Platform))))
+	w("lib_path=%s", shellQuote(filepath.Join(libDir, cfg.BinName)))
+	w("version_path=%s", shellQuote(versionDir(libDir, cfg.BinName, rel.Version)))
+	w("")
+	w("# Download, from the mirrors first")
+	w(`mkdir -p "$(dirname "$tarball")"`)
+	for _, u := range urls {
+		w("%s ||", downloadCommand(u))
+	}
+	w(`    { echo "download failed" >&2; exit 1; }`)
+	w("")
+	w("# Verify")
+	w("if command -v sha256sum >/dev/null 2>&1; then")
+	w(`    echo "$sha256  $tarball" | sha256sum -c -`)
+	w("else")
+	w(`    echo "$sha256  $tarball" | shasum -a 256 -c -`)
+	w("fi")
+	w("")
+	w("# Extract")
+	w(`rm -rf "$dest"`)
+	w(`mkdir -p "$dest"`)
+	extract := `tar -xf "$tarball" -C "$dest"`
+	zip := strings.HasSuffix(rel.Artifact.Tarball, ".zip")
+	if zip {
+		extract = `unzip -q "$tarball" -d "$dest"`
+	}
+	if cfg.StripComponents >= 0 && !zip {
+		w("%s --strip-components=%d", extract, cfg.StripComponents)
+		w(`root=$dest`)
+	} else {
+		w(extract)
+		w("# Upstream archives wrap everything in one directory")
+		w(`set -- "$dest"/*`)
+		w(`root=$dest`)
+		w(`if [ $# -eq 1 ] && [ -d "$1" ]; then root=$1; fi`)
+	}
+	w("")
+	w("# Install, replacing the previous version")
+	exe := binaryFile("zig", rel.Platform)
+	w(`mkdir -p "$(dirname "$bin_path")" "$(dirname "$lib_path")"`)
+	// Only a versioned directory the installer made, as for managedInstall
+	w(`if [ -L "$lib_path" ]; then`)
+	w(`    target=$(readlink "$lib_path")`)
+	w(`    case $target in`)
+	w(`    */*) ;;`)
+	w(`    "$(basename "$lib_path")"-*)`)
+	w(`        if [ -f "$(dirname "$lib_path")/$target/%s" ]; then rm -rf "$(dirname "$lib_path")/$target"; fi ;;`, manifestName)
+	w(`    esac`)
+	w(`fi`)
+	w(`rm -rf "$lib_path" "$version_path"`)
+	w(`rm -f "$bin_path"`)
+	if cfg.BinName == "zig" {
+		w(`mv "$root/%s" "$bin_path"`, exe)
+		w(`mv "$root/lib" "$version_path"`)
+		w(`ln -s "$(basename "$version_path")" "$lib_path"`)
+	} else {
+		// The layout of installRenamed
+		target, err := filepath.Rel(cfg.BinDir, filepath.Join(cfg.LibDir, cfg.BinName, exe))
+		if err != nil {
+			target = filepath.Join(cfg.LibDir, cfg.BinName, exe)
+		}
+		w(`mkdir -p "$version_path"`)
+		w(`mv "$root/%s" "$version_path/%s"`, exe, exe)
+		w(`mv "$root/lib" "$version_path/lib"`)
+		w(`ln -s "$(basename "$version_path")" "$lib_path"`)
+		w(`ln -s %s "$bin_path"`, shellQuote(target))
+	}
+	w(`rm -rf "$dest" "$tarball"`)
+	w(`echo "Zig %s installed to $bin_path"`, rel.Version)
+	return b.String()
+}
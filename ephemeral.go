@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ephemeralPattern names the throwaway prefixes of --ephemeral, which
+// --ephemeral-clean refuses to remove anything else than.
+const ephemeralPattern = "zig-ephemeral-*"
+
+// ephemeralEnv is set by the environment --ephemeral prints, so
+// --ephemeral-clean finds the prefix to remove.
+const ephemeralEnv = "ZIG_EPHEMERAL"
+
+// installEphemeral installs into a new temporary prefix and prints the
+// environment that puts it first on PATH, for eval in a shell session.
+// The prefix is marked like an extraction directory so
+// --ephemeral-clean can tell it was created here.
+func installEphemeral(cfg Config) error {
+	if cfg.Root != "" || cfg.DestOnly || cfg.InstallTarballTo != "" || cfg.JSON {
+		return newError(kindUsage, "--ephemeral installs into a prefix of its own; drop --root, --dest-only, --install-tarball-to and --json")
+	}
+	_, tmp := defaultDirs()
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return newError(kindFilesystem, "failed to create %s: %v", tmp, err)
+	}
+	prefix, err := os.MkdirTemp(tmp, ephemeralPattern)
+	if err != nil {
+		return newError(kindFilesystem, "failed to create an ephemeral prefix: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(prefix, destMarker), nil, 0644); err != nil {
+		os.RemoveAll(prefix)
+		return newError(kindFilesystem, "failed to mark %s: %v", prefix, err)
+	}
+	cfg.BinDir, cfg.LibDir = filepath.Join(prefix, "bin"), filepath.Join(prefix, "lib")
+	cfg.TarDest, cfg.Dest = filepath.Join(prefix, "zig.tar.xz"), filepath.Join(prefix, "extract")
+	cfg.Clean = true
+	cfg.log().Info("installing into ephemeral prefix %s", prefix)
+	if err := runInstallReported(cfg); err != nil {
+		os.RemoveAll(prefix)
+		return err
+	}
+	if _, err := os.Stat(cfg.BinDir); err != nil {
+		// Declined at the prompt
+		os.RemoveAll(prefix)
+		return nil
+	}
+
+	// Zig finds lib next to the real path of its binary, but the
+	// variable keeps that working when the binary is copied elsewhere
+	libDir := filepath.Join(cfg.LibDir, cfg.BinName)
+	if cfg.BinName != "zig" {
+		libDir = filepath.Join(libDir, "lib")
+	}
+	fmt.Printf("export %s=%s\n", ephemeralEnv, shellQuote(prefix))
+	fmt.Printf("export PATH=%s:\"$PATH\"\n", shellQuote(cfg.BinDir))
+	fmt.Printf("export ZIG_LIB_DIR=%s\n", shellQuote(libDir))
+	cfg.log().Info("run zig-installer --ephemeral-clean the same way to remove it again")
+	return nil
+}
+
+// cleanEphemeral removes the prefix of the --ephemeral install named by
+// ZIG_EPHEMERAL and prints the environment that undoes it.
+func cleanEphemeral(log Logger) error {
+	prefix := os.Getenv(ephemeralEnv)
+	if prefix == "" {
+		return newError(kindUsage, "%s is not set; run --ephemeral-clean in the shell that evaluated --ephemeral", ephemeralEnv)
+	}
+	if ok, _ := filepath.Match(ephemeralPattern, filepath.Base(prefix)); !ok || !ownsDir(prefix) {
+		return newError(kindFilesystem, "refusing to remove %s: it is not a prefix created by --ephemeral", prefix)
+	}
+	if err := os.RemoveAll(prefix); err != nil {
+		return newError(kindFilesystem, "failed to remove %s: %v", prefix, err)
+	}
+
+	bin := filepath.Join(prefix, "bin")
+	var path []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(dir) != bin {
+			path = append(path, dir)
+		}
+	}
+	fmt.Printf("export PATH=%s\n", shellQuote(strings.Join(path, string(os.PathListSeparator))))
+	fmt.Printf("unset ZIG_LIB_DIR %s\n", ephemeralEnv)
+	log.Success("removed ephemeral prefix %s", prefix)
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Exit codes. They are part of the CLI contract and listed in --help.
+const (
+	exitGeneric         = 1
+	exitUsage           = 2
+	exitNotFound        = 3
+	exitNetwork         = 4
+	exitChecksum        = 5
+	exitFilesystem      = 6
+	exitUpdateAvailable = 10
+	exitInterrupted     = 130
+)
+
+// exitCode is returned by commands whose result is an exit status rather
+// than an error message.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(c))
+}
+
+// errorKind classifies a failure so main can map it to an exit code.
+type errorKind int
+
+const (
+	kindGeneric errorKind = iota
+	kindUsage
+	kindNotFound
+	kindNetwork
+	kindChecksum
+	kindFilesystem
+	kindInterrupted
+)
+
+func (k errorKind) String() string {
+	switch k {
+	case kindUsage:
+		return "usage"
+	case kindNotFound:
+		return "not_found"
+	case kindNetwork:
+		return "network"
+	case kindChecksum:
+		return "checksum_mismatch"
+	case kindFilesystem:
+		return "filesystem"
+	case kindInterrupted:
+		return "interrupted"
+	}
+	return "error"
+}
+
+func (k errorKind) exitCode() int {
+	switch k {
+	case kindUsage:
+		return exitUsage
+	case kindNotFound:
+		return exitNotFound
+	case kindNetwork:
+		return exitNetwork
+	case kindChecksum:
+		return exitChecksum
+	case kindFilesystem:
+		return exitFilesystem
+	case kindInterrupted:
+		return exitInterrupted
+	}
+	return exitGeneric
+}
+
+// kindError attaches an errorKind to an error.
+type kindError struct {
+	kind errorKind
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// newError formats an error of the given kind.
+func newError(kind errorKind, format string, a ...interface{}) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, a...)}
+}
+
+// errorKindOf returns the kind of err. Untyped permission errors count as
+// filesystem failures.
+func errorKindOf(err error) errorKind {
+	var ke *kindError
+	if errors.As(err, &ke) {
+		return ke.kind
+	}
+	if errors.Is(err, os.ErrPermission) {
+		return kindFilesystem
+	}
+	return kindGeneric
+}
+
+// exitCodeFor maps err to the process exit status.
+func exitCodeFor(err error) int {
+	if code, ok := err.(exitCode); ok {
+		return int(code)
+	}
+	return errorKindOf(err).exitCode()
+}
+
+// detailedError is implemented by errors that carry structured details for
+// the --json error document.
+type detailedError interface {
+	details() map[string]interface{}
+}
+
+// checksumError is a file whose SHA-256 differs from the expected one.
+type checksumError struct {
+	expected string
+	got      string
+}
+
+func (e *checksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch: expected %s, got %s", e.expected, e.got)
+}
+
+func (e *checksumError) details() map[string]interface{} {
+	return map[string]interface{}{"expected": e.expected, "got": e.got}
+}
+
+// platformError is a release without a build for the platform asked for.
+// Every lookup that can miss a platform reports it through this one
+// message, which lists what the release does ship and how to get a build
+// for another platform.
+type platformError struct {
+	product  string
+	version  string
+	platform string
+	host     string
+	// reason comes first when the miss has a known cause, such as a host
+	// architecture Zig has no name for.
+	reason  string
+	offered []string
+	// newest is the newest version that ships platform. searched tells an
+	// empty newest found by looking from one nobody looked for.
+	newest   string
+	searched bool
+}
+
+func (e *platformError) Error() string {
+	var b strings.Builder
+	if e.reason != "" {
+		b.WriteString(e.reason + "; ")
+	}
+	fmt.Fprintf(&b, "%s %s has no build for %s", e.product, e.version, e.platform)
+	if e.platform == e.host {
+		b.WriteString(", the platform of this host")
+	} else {
+		fmt.Fprintf(&b, " (host platform is %s)", e.host)
+	}
+	fmt.Fprintf(&b, "; it ships for: %s", dashIfEmpty(strings.Join(e.offered, ", ")))
+	switch {
+	case e.newest != "":
+		fmt.Fprintf(&b, "; the newest version that does is %s", e.newest)
+	case e.searched:
+		fmt.Fprintf(&b, "; no version in the index ships for %s", e.platform)
+	}
+	if e.platform == e.host && len(e.offered) > 0 {
+		b.WriteString("; pass --target with one of those keys to fetch another platform's build")
+	}
+	return b.String()
+}
+
+func (e *platformError) details() map[string]interface{} {
+	d := map[string]interface{}{
+		"version":  e.version,
+		"platform": e.platform,
+		"host":     e.host,
+		"offered":  append([]string{}, e.offered...),
+	}
+	if e.newest != "" {
+		d["newest_with_platform"] = e.newest
+	}
+	return d
+}
+
+// errorDocument is printed on stdout for failures in --json mode.
+type errorDocument struct {
+	OK    bool        `json:"ok"`
+	Error errorObject `json:"error"`
+}
+
+type errorObject struct {
+	Kind     string                 `json:"kind"`
+	Message  string                 `json:"message"`
+	ExitCode int                    `json:"exit_code"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// printJSONError writes the --json error document for err.
+func printJSONError(err error) {
+	obj := errorObject{Kind: errorKindOf(err).String(), Message: err.Error(), ExitCode: exitCodeFor(err)}
+	var d detailedError
+	if errors.As(err, &d) {
+		obj.Details = d.details()
+	}
+	printJSON(errorDocument{Error: obj})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"untyped", fmt.Errorf("boom"), exitGeneric},
+		{"usage", newError(kindUsage, "bad flag"), exitUsage},
+		{"not found", newError(kindNotFound, "no such version"), exitNotFound},
+		{"network", newError(kindNetwork, "connection refused"), exitNetwork},
+		{"checksum", newError(kindChecksum, "mismatch"), exitChecksum},
+		{"filesystem", newError(kindFilesystem, "read-only"), exitFilesystem},
+		{"interrupted", newError(kindInterrupted, "interrupted"), exitInterrupted},
+		{"wrapped", fmt.Errorf("install: %w", newError(kindNetwork, "timeout")), exitNetwork},
+		{"permission", &os.PathError{Op: "open", Path: "/usr/local/bin/zig", Err: os.ErrPermission}, exitFilesystem},
+		{"exit code", exitCode(exitUpdateAvailable), exitUpdateAvailable},
+	}
+	for _, tt := range tests {
+		if got := exitCodeFor(tt.err); got != tt.want {
+			t.Errorf("%s: exitCodeFor(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestInstallExitCodes(t *testing.T) {
+	srv := serveReleases(t, map[string]string{"0.13.0": "0.13.0"})
+	tests := []struct {
+		name string
+		url  string
+		args []string
+		want int
+	}{
+		{"unknown version", srv.URL + "/index.json", []string{"-version", "0.99.0"}, exitNotFound},
+		{"unknown platform", srv.URL + "/index.json", []string{"-version", "0.13.0", "-target", "sparc64-plan9"}, exitNotFound},
+		{"unreachable index", "http://127.0.0.1:1/index.json", []string{"-version", "0.13.0"}, exitNetwork},
+		{"expected checksum", srv.URL + "/index.json", []string{"-version", "0.13.0", "-expected-sha256", strings.Repeat("0", 64)}, exitChecksum},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, tt.url, tt.args...)
+			cfg.Logger = silentLogger{}
+			err := runInstall(cfg)
+			if got := exitCodeFor(err); got != tt.want {
+				t.Errorf("exit code %d (%v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlatformErrorMessages(t *testing.T) {
+	offered := []string{"aarch64-linux", "aarch64-macos", "x86_64-linux", "x86_64-windows"}
+	tests := []struct {
+		golden string
+		err    *platformError
+	}{
+		{"foreign.txt", &platformError{product: "Zig", version: "0.13.0", platform: "x86_64-openbsd", host: "x86_64-linux", offered: offered, searched: true}},
+		{"host.txt", &platformError{product: "Zig", version: "0.13.0", platform: "aarch64-openbsd", host: "aarch64-openbsd", offered: offered, searched: true}},
+		{"newest.txt", &platformError{product: "Zig", version: "0.12.0", platform: "loongarch64-linux", host: "loongarch64-linux", offered: offered, newest: "0.14.0", searched: true}},
+		{"reason.txt", &platformError{product: "Zig", version: "0.13.0", platform: "mips-linux", host: "mips-linux", reason: `unsupported architecture: GOARCH "mips" has no Zig builds`, offered: offered, searched: true}},
+		{"zls.txt", &platformError{product: "ZLS", version: "0.13.0", platform: "x86-windows", host: "x86_64-linux"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			checkGolden(t, filepath.Join("platform-error", tt.golden), []byte(tt.err.Error()+"\n"))
+		})
+	}
+}
+
+func TestNoBuildError(t *testing.T) {
+	artifact := `{"tarball": "zig.tar.xz", "shasum": "` + strings.Repeat("0", 64) + `", "size": "1"}`
+	index, err := parseIndex([]byte(`{
+		"0.13.0": {"x86_64-linux": ` + artifact + `, "aarch64-macos": ` + artifact + `},
+		"0.12.0": {"x86_64-linux": ` + artifact + `, "riscv64-linux": ` + artifact + `}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = index.artifactFor(silentLogger{}, "0.13.0", "riscv64-linux", "")
+	var pe *platformError
+	if !errors.As(err, &pe) || errorKindOf(err) != kindNotFound {
+		t.Fatalf("artifactFor error %v, want a not found platformError", err)
+	}
+	if !reflect.DeepEqual(pe.offered, []string{"aarch64-macos", "x86_64-linux"}) || pe.newest != "0.12.0" {
+		t.Errorf("offered %v and newest %s, want aarch64-macos, x86_64-linux and 0.12.0", pe.offered, pe.newest)
+	}
+	d := pe.details()
+	if d["newest_with_platform"] != "0.12.0" || d["platform"] != "riscv64-linux" {
+		t.Errorf("details %v", d)
+	}
+}
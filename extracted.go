@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// minZigBinarySize is the smallest plausible size of the zig binary. Real
+// builds are tens of megabytes; anything tiny is a stub or a truncated
+// file.
+const minZigBinarySize = 1000 * 1000
+
+// checkExtractedTree makes sure dest holds what a Zig release tarball for
+// platformKey contains before anything already installed is touched. It
+// returns the path of the zig binary, see locateBinary.
+func checkExtractedTree(log Logger, dest, platformKey string) (string, error) {
+	var problems []string
+
+	bin := locateBinary(log, dest, platformKey)
+	switch info, err := os.Lstat(bin); {
+	case err != nil:
+		problems = append(problems, "zig binary: expected a file, found nothing")
+	case !info.Mode().IsRegular():
+		problems = append(problems, fmt.Sprintf("zig binary: expected a regular file, found %s", describeMode(info.Mode())))
+	case info.Size() < minZigBinarySize:
+		problems = append(problems, fmt.Sprintf("zig binary: expected at least %s, found %s", formatBytes(minZigBinarySize), formatBytes(info.Size())))
+	case info.Mode().Perm()&0111 == 0 && !isWindowsKey(platformKey):
+		problems = append(problems, fmt.Sprintf("zig binary: expected an executable, found mode %v", info.Mode().Perm()))
+	}
+
+	lib := filepath.Join(dest, "lib")
+	switch info, err := os.Stat(lib); {
+	case err != nil:
+		problems = append(problems, "lib: expected a directory, found nothing")
+	case !info.IsDir():
+		problems = append(problems, fmt.Sprintf("lib: expected a directory, found %s", describeMode(info.Mode())))
+	default:
+		if _, err := os.Stat(filepath.Join(lib, "std", "std.zig")); err != nil {
+			problems = append(problems, "lib: expected std/std.zig, found none")
+		}
+	}
+
+	if len(problems) == 0 {
+		return bin, nil
+	}
+	found := "nothing"
+	if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+		var names []string
+		for _, e := range entries {
+			if e.Name() == destMarker {
+				continue
+			}
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+		found = strings.Join(names, ", ")
+	}
+	return "", fmt.Errorf("unexpected tarball layout:\n  %s\n  (archive top level contains: %s)", strings.Join(problems, "\n  "), found)
+}
+
+// locateBinary returns the path of the zig binary in the extracted tree
+// at dest. It is expected at the top under the platform's name, but zig
+// and zig.exe are looked for anywhere outside lib too, shallowest first,
+// so archives that misname it or move it into a subdirectory still
+// install. Without a match the expected path is returned.
+func locateBinary(log Logger, dest, platformKey string) string {
+	expected := filepath.Join(dest, binaryFile("zig", platformKey))
+	if info, err := os.Lstat(expected); err == nil && info.Mode().IsRegular() {
+		return expected
+	}
+
+	found, depth := "", 0
+	filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && path == filepath.Join(dest, "lib") {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() || (d.Name() != "zig" && d.Name() != "zig.exe") {
+			return nil
+		}
+		if n := strings.Count(path, string(filepath.Separator)); found == "" || n < depth {
+			found, depth = path, n
+		}
+		return nil
+	})
+	if found == "" {
+		return expected
+	}
+	rel, _ := filepath.Rel(dest, found)
+	log.Info("the archive has no %s at the top, using %s", binaryFile("zig", platformKey), rel)
+	return found
+}
+
+func describeMode(m os.FileMode) string {
+	switch {
+	case m.IsDir():
+		return "a directory"
+	case m&os.ModeSymlink != 0:
+		return "a symlink"
+	case m.IsRegular():
+		return "a regular file"
+	}
+	return "a special file"
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// githubChecksums is the release asset listing the SHA-256 of every other
+// asset of a release.
+const githubChecksums = "SHASUMS256.txt"
+
+// githubReleaseBase returns the download URL prefix of the release tagged
+// version of repo, given as owner/name or as a URL up to the
+// releases/download part for GitHub Enterprise hosts.
+func githubReleaseBase(repo, version string) string {
+	base := strings.TrimSuffix(repo, "/")
+	if !strings.Contains(base, "://") {
+		base = "https://github.com/" + base + "/releases/download"
+	}
+	return base + "/" + version
+}
+
+// isStableVersion reports whether version is a tagged release, the only
+// kind GitHub releases are made for.
+func isStableVersion(version string) bool {
+	v, err := parseVersion(version)
+	return err == nil && !v.isPrerelease()
+}
+
+// downloadFromGitHub is the fallback for a stable release that neither the
+// mirrors nor upstream delivered: it fetches name from the release of
+// version in repo. The SHA-256 comes from the release's SHASUMS256.txt
+// asset and has to agree with the index's shasum, so the fallback is held
+// to the same checksum as any other download.
+func downloadFromGitHub(log Logger, client *http.Client, repo, version, name, shasum, dest string, maxSize int64, progress ProgressFunc) error {
+	base := githubReleaseBase(repo, version)
+	listed, err := fetchChecksum(client, base+"/"+githubChecksums, name)
+	if err != nil {
+		return newError(kindChecksum, "GitHub fallback: no checksum for %s: %v", name, err)
+	}
+	if listed != shasum {
+		return newError(kindChecksum, "index shasum %s does not match %s from %s", shasum, listed, base+"/"+githubChecksums)
+	}
+	log.Info("downloading %s from the GitHub release %s", name, base)
+	if err := downloadFile(log, client, base+"/"+name, dest, maxSize, progress); err != nil {
+		return newError(kindNetwork, "GitHub fallback: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// serveGitHubFallback serves an index listing a 0.13.0 tarball for the
+// host platform that upstream no longer has, and a GitHub style release
+// of it under /releases/download whose SHASUMS256.txt lists sums.
+// A nil sums lists the tarball's real checksum.
+func serveGitHubFallback(t *testing.T, sums map[string]string) *httptest.Server {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake zig binary is a shell script")
+	}
+	platform := getPlatformKey()
+	top := "zig-" + platform + "-0.13.0"
+	data, err := os.ReadFile(writeTarGz(t, t.TempDir(), map[string]string{
+		top + "/zig":                 fakeZig("0.13.0"),
+		top + "/lib/std/std.zig":     "",
+		top + "/lib/std/mem.zig":     "",
+		top + "/doc/langref.html":    "",
+		top + "/LICENSE":             "",
+		top + "/lib/compiler_rt.zig": "",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := top + ".tar.gz"
+	sum := sha256.Sum256(data)
+	shasum := hex.EncodeToString(sum[:])
+	if sums == nil {
+		sums = map[string]string{name: shasum}
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	index, err := json.Marshal(map[string]interface{}{
+		"0.13.0": map[string]interface{}{
+			platform: map[string]string{"tarball": srv.URL + "/gone/" + name, "shasum": shasum, "size": "1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) { w.Write(index) })
+	mux.HandleFunc("/releases/download/0.13.0/"+name, func(w http.ResponseWriter, r *http.Request) { w.Write(data) })
+	mux.HandleFunc("/releases/download/0.13.0/SHASUMS256.txt", func(w http.ResponseWriter, r *http.Request) {
+		for file, sum := range sums {
+			fmt.Fprintf(w, "%s  %s\n", sum, file)
+		}
+	})
+	return srv
+}
+
+func TestGitHubReleaseBase(t *testing.T) {
+	tests := map[string]string{
+		"example/zig-mirror":  "https://github.com/example/zig-mirror/releases/download/0.13.0",
+		"example/zig-mirror/": "https://github.com/example/zig-mirror/releases/download/0.13.0",
+		"https://git.example.com/example/zig/releases/download": "https://git.example.com/example/zig/releases/download/0.13.0",
+	}
+	for repo, want := range tests {
+		if got := githubReleaseBase(repo, "0.13.0"); got != want {
+			t.Errorf("githubReleaseBase(%q) = %s, want %s", repo, got, want)
+		}
+	}
+}
+
+func TestInstallFallsBackToGitHub(t *testing.T) {
+	srv := serveGitHubFallback(t, nil)
+	cfg := testConfig(t, srv.URL+"/index.json", "-version", "0.13.0", "-github-fallback", srv.URL+"/releases/download")
+	rec := &recordingLogger{}
+	cfg.Logger = rec
+	if err := runInstall(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := zigVersionOf(filepath.Join(cfg.BinDir, "zig")); err != nil || got != "0.13.0" {
+		t.Errorf("installed binary reports %q, %v", got, err)
+	}
+	if got := rec.Messages("info"); !containsMessage(got, "from the GitHub release") {
+		t.Errorf("info messages %q do not mention the fallback", got)
+	}
+}
+
+func TestGitHubFallbackChecksums(t *testing.T) {
+	name := "zig-" + getPlatformKey() + "-0.13.0.tar.gz"
+	tests := []struct {
+		name string
+		sums map[string]string
+		want string
+	}{
+		{"not listed", map[string]string{"zig-other-0.13.0.tar.gz": strings.Repeat("a", 64)}, "no checksum for " + name},
+		{"disagrees with the index", map[string]string{name: strings.Repeat("a", 64)}, "does not match " + strings.Repeat("a", 64)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serveGitHubFallback(t, tt.sums)
+			cfg := testConfig(t, srv.URL+"/index.json", "-version", "0.13.0", "-github-fallback", srv.URL+"/releases/download")
+			cfg.Logger = silentLogger{}
+			err := runInstall(cfg)
+			if errorKindOf(err) != kindChecksum || !strings.Contains(fmt.Sprint(err), tt.want) {
+				t.Fatalf("install error %v, want a checksum error containing %q", err, tt.want)
+			}
+			if _, err := os.Stat(cfg.TarDest); !os.IsNotExist(err) {
+				t.Error("the tarball was downloaded from GitHub anyway")
+			}
+		})
+	}
+}
+
+func TestNoGitHubFallbackWithoutFlag(t *testing.T) {
+	srv := serveGitHubFallback(t, nil)
+	cfg := testConfig(t, srv.URL+"/index.json", "-version", "0.13.0")
+	cfg.Logger = silentLogger{}
+	if err := runInstall(cfg); errorKindOf(err) != kindNetwork {
+		t.Errorf("install error %v, want the network error of the failed download", err)
+	}
+}
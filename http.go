@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// newHTTPClient builds the client shared by the index fetch and the
+// tarball download so both honor the same transport settings.
+func newHTTPClient(cfg Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	minVersion := uint16(tls.VersionTLS12)
+	if cfg.MinTLSVersion != "" {
+		var err error
+		if minVersion, err = parseTLSVersion(cfg.MinTLSVersion); err != nil {
+			return nil, err
+		}
+	}
+	transport.TLSClientConfig = &tls.Config{MinVersion: minVersion}
+
+	if cfg.PinCert != "" {
+		pin, err := parseCertPin(cfg.PinCert)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return fmt.Errorf("pinned certificate check failed: server sent no certificate")
+			}
+			sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+			got := hex.EncodeToString(sum[:])
+			if got != pin {
+				return fmt.Errorf("pinned certificate mismatch for %s: expected %s, got %s", cs.ServerName, pin, got)
+			}
+			return nil
+		}
+	}
+
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent()
+	}
+	log := cfg.log()
+	tlsVersion := tlsVersionTransport{base: transport, min: minVersion}
+	plain := &plainHTTPTransport{base: tlsVersion, refuse: cfg.RequireHTTPS, log: log}
+	client := &http.Client{Transport: userAgentTransport{debugTransport{plain, log}, userAgent}}
+	if pinned := splitList(cfg.PinHost); len(pinned) > 0 {
+		client.CheckRedirect = pinnedRedirects(pinned, log)
+	}
+	return client, nil
+}
+
+// pinnedRedirects only follows redirects to one of the pinned hosts or
+// their subdomains, so a redirect cannot send requests somewhere untrusted.
+func pinnedRedirects(pinned []string, log Logger) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		host := req.URL.Hostname()
+		for _, p := range pinned {
+			if host == p || strings.HasSuffix(host, "."+p) {
+				log.Debug("following redirect from %s to %s", via[len(via)-1].URL, req.URL)
+				return nil
+			}
+		}
+		return fmt.Errorf("refusing redirect from %s to %s: host %s is not in --pin-host", via[len(via)-1].URL.Host, req.URL, host)
+	}
+}
+
+// defaultUserAgent identifies the installer and its version.
+func defaultUserAgent() string {
+	return "zig-installer/" + installerVersion()
+}
+
+// userAgentTransport sets the User-Agent header on every request, which
+// some mirrors and WAFs require instead of Go's default.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// contextTransport ties every request to ctx, replacing its own context,
+// so a batch can abandon the downloads still running once one failed.
+type contextTransport struct {
+	base http.RoundTripper
+	ctx  context.Context
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
+
+// withContext returns a copy of client whose requests are canceled with
+// ctx.
+func withContext(client *http.Client, ctx context.Context) *http.Client {
+	c := *client
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.Transport = contextTransport{base: base, ctx: ctx}
+	return &c
+}
+
+// debugTransport logs every request and its status with --verbose. It
+// sits below the client, so redirects show up as requests of their own.
+type debugTransport struct {
+	base http.RoundTripper
+	log  Logger
+}
+
+func (t debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.log.Debug("%s %s: %v", req.Method, req.URL, err)
+		return resp, err
+	}
+	t.log.Debug("%s %s: %s", req.Method, req.URL, resp.Status)
+	return resp, nil
+}
+
+// plainHTTPTransport warns about requests made over plain HTTP, once per
+// host, or refuses them with --require-https. Checksums catch a tampered
+// tarball, but not an index swapped for one listing a different tarball
+// and its matching checksum. Sitting in the transport, it also sees
+// redirects and every other URL the installer fetches.
+type plainHTTPTransport struct {
+	base   http.RoundTripper
+	refuse bool
+	log    Logger
+	warned sync.Map
+}
+
+func (t *plainHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		if t.refuse {
+			return nil, fmt.Errorf("refusing to fetch %s over plain HTTP (--require-https)", req.URL)
+		}
+		if _, seen := t.warned.LoadOrStore(req.URL.Host, true); !seen {
+			t.log.Warning("fetching from %s over plain HTTP, which anyone on the network path can tamper with; use https, or --require-https to refuse", req.URL.Host)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// tlsVersions are the TLS versions --min-tls-version accepts.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion parses a --min-tls-version value such as "1.3".
+func parseTLSVersion(s string) (uint16, error) {
+	v, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(s), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version %q: expected 1.0, 1.1, 1.2 or 1.3", s)
+	}
+	return v, nil
+}
+
+// tlsVersionTransport explains handshakes that failed because the server
+// only speaks TLS versions older than --min-tls-version, which Go reports
+// as a bare protocol version error.
+type tlsVersionTransport struct {
+	base http.RoundTripper
+	min  uint16
+}
+
+func (t tlsVersionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil && strings.Contains(err.Error(), "protocol version") {
+		return nil, fmt.Errorf("%s does not support %s or newer, which --min-tls-version requires: %v", req.URL.Host, tls.VersionName(t.min), err)
+	}
+	return resp, err
+}
+
+// parseCertPin normalizes a sha256 fingerprint given either as plain hex
+// or in the colon separated form printed by openssl.
+func parseCertPin(s string) (string, error) {
+	pin := strings.ToLower(strings.ReplaceAll(s, ":", ""))
+	if len(pin) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid certificate pin %q: expected a sha256 fingerprint", s)
+	}
+	if _, err := hex.DecodeString(pin); err != nil {
+		return "", fmt.Errorf("invalid certificate pin %q: %v", s, err)
+	}
+	return pin, nil
+}
@@ -0,0 +1,672 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Index is the decoded download index, keyed by version ("master",
+// "0.13.0", ...).
+type Index map[string]VersionEntry
+
+// VersionEntry is a single release of the index. The metadata fields are
+// split off during decoding and every remaining object is treated as a
+// platform artifact keyed by its platform key (e.g. "x86_64-linux").
+type VersionEntry struct {
+	Version   string
+	Date      string
+	Docs      string
+	StdDocs   string
+	Notes     string
+	Src       *Artifact
+	Bootstrap *Artifact
+	Artifacts map[string]Artifact
+	// Variants holds the platforms the index lists several CPU variants
+	// of (e.g. baseline and x86_64_v3) instead of a single artifact.
+	Variants map[string]map[string]Artifact
+
+	// Origin is the index URL the entry came from when several indexes
+	// were merged. It is not part of the index format.
+	Origin string
+}
+
+// Artifact is a downloadable tarball together with its checksum.
+type Artifact struct {
+	Tarball string `json:"tarball"`
+	Shasum  string `json:"shasum"`
+	Size    int64  `json:"size"`
+}
+
+// metadataFields are the string valued keys of a version entry that are
+// not platforms.
+var metadataFields = map[string]func(*VersionEntry) *string{
+	"version": func(e *VersionEntry) *string { return &e.Version },
+	"date":    func(e *VersionEntry) *string { return &e.Date },
+	"docs":    func(e *VersionEntry) *string { return &e.Docs },
+	"stdDocs": func(e *VersionEntry) *string { return &e.StdDocs },
+	"notes":   func(e *VersionEntry) *string { return &e.Notes },
+}
+
+// Keys returns every version key of the index.
+func (idx Index) Keys() []string {
+	keys := make([]string, 0, len(idx))
+	for k := range idx {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// keyForVersion returns the key whose entry points at the concrete
+// version v, e.g. "master" for the current dev build.
+func (idx Index) keyForVersion(v string) string {
+	for key, entry := range idx {
+		if entry.Version == v {
+			return key
+		}
+	}
+	return ""
+}
+
+// platforms returns the platform keys this release ships artifacts for.
+func (e VersionEntry) platforms() []string {
+	keys := make([]string, 0, len(e.Artifacts)+len(e.Variants))
+	for k := range e.Artifacts {
+		keys = append(keys, k)
+	}
+	for k := range e.Variants {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// legacyArchs maps architecture names to the ones older index entries
+// use for them: releases before 0.11.0 publish 32-bit x86 as i386.
+var legacyArchs = map[string]string{
+	"x86": "i386",
+}
+
+// defaultCPUVariants are the names of the build that runs on every CPU of
+// an architecture, picked when --cpu-variant is not given.
+var defaultCPUVariants = []string{"baseline", "generic"}
+
+// entryKey returns the key the release lists platform under, which is
+// the platform's legacy name for older releases, or "".
+func (e VersionEntry) entryKey(platform string) string {
+	has := func(key string) bool {
+		_, single := e.Artifacts[key]
+		_, several := e.Variants[key]
+		return single || several
+	}
+	if has(platform) {
+		return platform
+	}
+	arch, os := splitPlatformKey(platform)
+	if legacy, ok := legacyArchs[arch]; ok && has(legacy+"-"+os) {
+		return legacy + "-" + os
+	}
+	return ""
+}
+
+// artifact returns the artifact of the release for platform, also under
+// the platform's legacy name. Of several CPU variants it returns the
+// baseline one, if any.
+func (e VersionEntry) artifact(platform string) (Artifact, bool) {
+	key := e.entryKey(platform)
+	if a, ok := e.Artifacts[key]; ok {
+		return a, true
+	}
+	for _, name := range defaultCPUVariants {
+		if a, ok := e.Variants[key][name]; ok {
+			return a, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// cpuVariant returns the artifact of the named CPU variant of platform,
+// or of the baseline build when variant is empty. A platform with a
+// single artifact counts as having only the baseline variant.
+func (e VersionEntry) cpuVariant(log Logger, version, platform, variant string) (Artifact, error) {
+	key := e.entryKey(platform)
+	variants, ok := e.Variants[key]
+	if !ok {
+		a := e.Artifacts[key]
+		if variant == "" || isDefaultCPUVariant(variant) {
+			return a, nil
+		}
+		return Artifact{}, newError(kindNotFound, "Zig %s ships a single build for %s, there is no %q CPU variant; drop --cpu-variant", version, platform, variant)
+	}
+
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if variant == "" {
+		for _, name := range defaultCPUVariants {
+			if a, ok := variants[name]; ok {
+				log.Debug("using the %s CPU variant of %s (available: %s)", name, platform, strings.Join(names, ", "))
+				return a, nil
+			}
+		}
+		return Artifact{}, newError(kindNotFound, "Zig %s ships %s only in CPU variants without a baseline build (%s); pick one with --cpu-variant",
+			version, platform, strings.Join(names, ", "))
+	}
+	if a, ok := variants[variant]; ok {
+		log.Info("using the %s CPU variant of %s", variant, platform)
+		return a, nil
+	}
+	return Artifact{}, newError(kindNotFound, "Zig %s has no %q CPU variant for %s; it ships: %s", version, variant, platform, strings.Join(names, ", "))
+}
+
+func isDefaultCPUVariant(name string) bool {
+	for _, v := range defaultCPUVariants {
+		if name == v {
+			return true
+		}
+	}
+	return false
+}
+
+// versionsByPlatform inverts the index: it maps every platform key to the
+// version keys that ship an artifact for it. Legacy names are folded into
+// the current ones.
+func (idx Index) versionsByPlatform() map[string][]string {
+	legacy := make(map[string]string, len(legacyArchs))
+	for current, old := range legacyArchs {
+		legacy[old] = current
+	}
+	byPlatform := make(map[string][]string)
+	for version, entry := range idx {
+		for _, key := range entry.platforms() {
+			if arch, os := splitPlatformKey(key); legacy[arch] != "" {
+				key = legacy[arch] + "-" + os
+			}
+			byPlatform[key] = append(byPlatform[key], version)
+		}
+	}
+	return byPlatform
+}
+
+// newestWith returns the newest version key other than skip that ships
+// for platform, preferring tagged releases over master.
+func (idx Index) newestWith(platform, skip string) string {
+	var versions []string
+	for _, v := range idx.versionsByPlatform()[platform] {
+		if v != skip {
+			versions = append(versions, v)
+		}
+	}
+	if sorted := sortedVersions(versions); len(sorted) > 0 {
+		return sorted[0].String()
+	}
+	if len(versions) > 0 {
+		sort.Strings(versions)
+		return versions[0]
+	}
+	return ""
+}
+
+// artifactFor looks up the artifact of version for platform, picking the
+// CPU variant named by variant (the baseline build when empty) where the
+// index lists several. The error tells a platform this index never ships
+// apart from one the version lacks, and in the latter case names the
+// newest version that has it.
+func (idx Index) artifactFor(log Logger, version, platform, variant string) (Artifact, error) {
+	entry := idx[version]
+	if entry.entryKey(platform) != "" {
+		return entry.cpuVariant(log, version, platform, variant)
+	}
+	return Artifact{}, idx.noBuildError(version, platform, "")
+}
+
+// noBuildError is the platformError for version lacking platform, with
+// reason put in front when given.
+func (idx Index) noBuildError(version, platform, reason string) error {
+	return &kindError{kind: kindNotFound, err: &platformError{
+		product:  "Zig",
+		version:  version,
+		platform: platform,
+		host:     getPlatformKey(),
+		reason:   reason,
+		offered:  idx[version].platforms(),
+		newest:   idx.newestWith(platform, version),
+		searched: true,
+	}}
+}
+
+func (idx *Index) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*idx = make(Index, len(raw))
+	for version, value := range raw {
+		var e VersionEntry
+		if err := json.Unmarshal(value, &e); err != nil {
+			return fmt.Errorf("%s: %v", version, err)
+		}
+		(*idx)[version] = e
+	}
+	return nil
+}
+
+func (e *VersionEntry) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*e = VersionEntry{Artifacts: make(map[string]Artifact)}
+	for key, value := range raw {
+		if field, ok := metadataFields[key]; ok {
+			if err := json.Unmarshal(value, field(e)); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+			continue
+		}
+
+		// Unknown scalar metadata is tolerated so new upstream fields
+		// don't break older installers.
+		if !strings.HasPrefix(strings.TrimSpace(string(value)), "{") {
+			continue
+		}
+
+		if variants, ok, err := decodeVariants(value); ok {
+			if err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+			if e.Variants == nil {
+				e.Variants = make(map[string]map[string]Artifact)
+			}
+			e.Variants[key] = variants
+			continue
+		}
+
+		var a Artifact
+		if err := json.Unmarshal(value, &a); err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		switch key {
+		case "src":
+			e.Src = &a
+		case "bootstrap":
+			e.Bootstrap = &a
+		default:
+			e.Artifacts[key] = a
+		}
+	}
+	return nil
+}
+
+func (e VersionEntry) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(e.Artifacts)+7)
+	for key, field := range metadataFields {
+		if v := *field(&e); v != "" {
+			out[key] = v
+		}
+	}
+	if e.Src != nil {
+		out["src"] = e.Src
+	}
+	if e.Bootstrap != nil {
+		out["bootstrap"] = e.Bootstrap
+	}
+	for key, a := range e.Artifacts {
+		out[key] = a
+	}
+	for key, variants := range e.Variants {
+		out[key] = variants
+	}
+	return json.Marshal(out)
+}
+
+// decodeVariants decodes a platform value that maps CPU variant names to
+// artifacts instead of being an artifact itself. ok reports whether value
+// has that shape: an object without a tarball whose members are objects.
+func decodeVariants(value json.RawMessage) (variants map[string]Artifact, ok bool, err error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(value, &raw); err != nil || len(raw) == 0 {
+		return nil, false, nil
+	}
+	if _, single := raw["tarball"]; single {
+		return nil, false, nil
+	}
+	for _, v := range raw {
+		if !strings.HasPrefix(strings.TrimSpace(string(v)), "{") {
+			return nil, false, nil
+		}
+	}
+	variants = make(map[string]Artifact, len(raw))
+	for name, v := range raw {
+		var a Artifact
+		if err := json.Unmarshal(v, &a); err != nil {
+			return nil, true, fmt.Errorf("CPU variant %s: %v", name, err)
+		}
+		variants[name] = a
+	}
+	return variants, true, nil
+}
+
+func (a *Artifact) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Tarball string          `json:"tarball"`
+		Shasum  string          `json:"shasum"`
+		Size    json.RawMessage `json:"size"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Tarball == "" {
+		return fmt.Errorf("missing tarball")
+	}
+	if err := validateShasum(raw.Shasum); err != nil {
+		return err
+	}
+
+	// The upstream index encodes the size as a string.
+	var size int64
+	if len(raw.Size) > 0 {
+		var s string
+		if err := json.Unmarshal(raw.Size, &s); err == nil {
+			size, err = strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid size %q", s)
+			}
+		} else if err := json.Unmarshal(raw.Size, &size); err != nil {
+			return fmt.Errorf("invalid size %s", raw.Size)
+		}
+	}
+	if size < 0 {
+		return fmt.Errorf("invalid size %d", size)
+	}
+
+	*a = Artifact{Tarball: raw.Tarball, Shasum: raw.Shasum, Size: size}
+	return nil
+}
+
+// MarshalJSON writes the artifact in the upstream shape, size included as
+// a string.
+func (a Artifact) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Tarball string `json:"tarball"`
+		Shasum  string `json:"shasum"`
+		Size    string `json:"size"`
+	}{a.Tarball, a.Shasum, strconv.FormatInt(a.Size, 10)})
+}
+
+// validateShasum checks that s is a lowercase hex encoded sha256 digest.
+func validateShasum(s string) error {
+	if len(s) != 64 {
+		return fmt.Errorf("invalid shasum %q: expected 64 hex characters", s)
+	}
+	if s != strings.ToLower(s) {
+		return fmt.Errorf("invalid shasum %q: expected lowercase hex", s)
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return fmt.Errorf("invalid shasum %q: %v", s, err)
+	}
+	return nil
+}
+
+// resolveTarballURL resolves a tarball path relative to the URL of the
+// index listing it, as self-hosted indexes may keep their paths relative
+// for portability. Absolute URLs, SSH sources and paths in local index
+// files are returned as they are.
+func resolveTarballURL(tarball, base string) string {
+	if tarball == "" || strings.Contains(tarball, "://") {
+		return tarball
+	}
+	if _, ok := parseSSHSource(tarball); ok {
+		return tarball
+	}
+	if src, ok := parseSSHSource(base); ok && !strings.Contains(base, "://") {
+		// url.Parse can't take host:path
+		if strings.HasPrefix(tarball, "/") {
+			src.path = tarball
+		} else {
+			src.path = path.Join(path.Dir(src.path), tarball)
+		}
+		return src.target()
+	}
+	b, err := url.Parse(base)
+	if err != nil || b.Host == "" {
+		return tarball
+	}
+	ref, err := url.Parse(tarball)
+	if err != nil {
+		return tarball
+	}
+	return b.ResolveReference(ref).String()
+}
+
+// resolveTarballs resolves the relative tarball paths of every artifact
+// against base, the URL the index was fetched from. Tarballs are never
+// read from the local filesystem, so a relative path in an --index-file
+// or file:// index is an error.
+func (idx Index) resolveTarballs(base string) error {
+	var unresolved string
+	resolve := func(a *Artifact) {
+		a.Tarball = resolveTarballURL(a.Tarball, base)
+		if _, ok := parseSSHSource(a.Tarball); !ok && !strings.Contains(a.Tarball, "://") && unresolved == "" {
+			unresolved = a.Tarball
+		}
+	}
+	for key, entry := range idx {
+		for _, a := range []*Artifact{entry.Src, entry.Bootstrap} {
+			if a != nil {
+				resolve(a)
+			}
+		}
+		for platform, a := range entry.Artifacts {
+			resolve(&a)
+			entry.Artifacts[platform] = a
+		}
+		for platform, variants := range entry.Variants {
+			for variant, a := range variants {
+				resolve(&a)
+				entry.Variants[platform][variant] = a
+			}
+		}
+		idx[key] = entry
+	}
+	if unresolved != "" {
+		return newError(kindGeneric, "relative tarball path %q in index %s needs an http(s) or sftp index URL to resolve against; list full tarball URLs in local indexes", unresolved, base)
+	}
+	return nil
+}
+
+// validateTarballURL checks a tarball URL from the index before it is
+// downloaded: it has to be an http(s) URL on the index host (or one of
+// its subdomains, as the Mach index uses) or on one of allowedHosts.
+// allowForeign skips the host check.
+func validateTarballURL(tarballURL, indexURL string, allowedHosts []string, allowForeign bool) error {
+	host := ""
+	if src, ok := parseSSHSource(tarballURL); ok {
+		host = src.host
+	} else {
+		u, err := url.Parse(tarballURL)
+		if err != nil {
+			return newError(kindGeneric, "invalid tarball URL %q in index: %v", tarballURL, err)
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return newError(kindGeneric, "invalid tarball URL %q in index: scheme %q is not allowed", tarballURL, u.Scheme)
+		}
+		if u.Host == "" {
+			return newError(kindGeneric, "invalid tarball URL %q in index: missing host", tarballURL)
+		}
+		host = u.Hostname()
+	}
+	if allowForeign {
+		return nil
+	}
+
+	for _, index := range splitList(indexURL) {
+		// A local index is as trusted as the user who points at it
+		if strings.HasPrefix(index, "file://") {
+			return nil
+		}
+		if ih := urlHost(index); ih != "" && (host == ih || strings.HasSuffix(host, "."+ih)) {
+			return nil
+		}
+	}
+	for _, h := range allowedHosts {
+		if host == h {
+			return nil
+		}
+	}
+	return newError(kindGeneric, "tarball URL %q in index points at foreign host %s (use --allow-foreign-host to accept it)", tarballURL, host)
+}
+
+// mirrorHosts returns the hosts of the configured mirrors, which tarball
+// URLs are allowed to point at besides the index host.
+func mirrorHosts(mirrors string) []string {
+	var hosts []string
+	for _, m := range splitList(mirrors) {
+		if h := urlHost(m); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// urlHost returns the host name of an index, mirror or tarball URL, SSH
+// sources included, or "".
+func urlHost(s string) string {
+	if src, ok := parseSSHSource(s); ok {
+		return src.host
+	}
+	if u, err := url.Parse(s); err == nil {
+		return u.Hostname()
+	}
+	return ""
+}
+
+// maxIndexSize caps how much of an index response is read. The upstream
+// index is a few hundred kilobytes, so this leaves plenty of headroom.
+const maxIndexSize = 16 << 20
+
+// defaultIndexTimeout bounds an index request from connecting to the last
+// byte. The index is small, so a fetch taking longer is hung rather than
+// slow; tarball downloads get no such deadline.
+const defaultIndexTimeout = 10 * time.Second
+
+// indexResponse is a downloaded index document.
+type indexResponse struct {
+	Data []byte
+	ETag string
+	// URL is where the document was fetched from after redirects.
+	URL string
+}
+
+// fetchIndexData downloads the raw index document, refusing to read more
+// than maxIndexSize bytes or to take longer than timeout (0 for no limit).
+// When etag is set and the server answers 304 Not Modified, the returned
+// Data is nil.
+func fetchIndexData(log Logger, client *http.Client, url, etag string, timeout time.Duration) (indexResponse, error) {
+	if src, ok := parseSSHSource(url); ok {
+		return fetchIndexSSH(log, url, src, timeout)
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return indexResponse{}, newError(kindUsage, "invalid index URL %q: %v", url, err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return indexResponse{}, indexFetchError(ctx, url, timeout, err)
+	}
+	defer resp.Body.Close()
+
+	final := resp.Request.URL.String()
+	if final != url {
+		log.Info("index %s redirected to %s", url, final)
+	}
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return indexResponse{ETag: etag, URL: final}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return indexResponse{}, newError(kindNetwork, "failed to fetch index from %s: HTTP %d", final, resp.StatusCode)
+	}
+
+	if resp.ContentLength > maxIndexSize {
+		return indexResponse{}, fmt.Errorf("index is %s, refusing to read more than %s", formatBytes(resp.ContentLength), formatBytes(maxIndexSize))
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(nil, resp.Body, maxIndexSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return indexResponse{}, fmt.Errorf("index exceeds %s, refusing to read further", formatBytes(maxIndexSize))
+		}
+		return indexResponse{}, indexFetchError(ctx, url, timeout, err)
+	}
+	return indexResponse{Data: data, ETag: resp.Header.Get("ETag"), URL: final}, nil
+}
+
+// indexFetchError is the network error of a failed index request, saying
+// so plainly when the request ran out of time.
+func indexFetchError(ctx context.Context, url string, timeout time.Duration, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return newError(kindNetwork, "index request to %s timed out after %s; raise --index-timeout on slow links", url, timeout)
+	}
+	return newError(kindNetwork, "failed to fetch index: %v", err)
+}
+
+// indexFileURL turns an --index-file path into the file:// URL that index
+// URLs are handled as.
+func indexFileURL(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return "file://" + filepath.ToSlash(path)
+}
+
+// readIndexFile loads the index of --index-file, never cached. It gets
+// the same structural checks as a fetched one.
+func readIndexFile(path string, parse indexParser) (Index, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", newError(kindNotFound, "--index-file: %v", err)
+	}
+	index, err := parse(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %v", path, err)
+	}
+	return index, "", nil
+}
+
+// parseIndex decodes a raw index document.
+func parseIndex(data []byte) (Index, error) {
+	if err := checkIndexShape(data); err != nil {
+		return nil, err
+	}
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %v", err)
+	}
+	return index, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testdata/index/upstream.json has the layout of
+// ziglang.org/download/index.json, trimmed to master and two releases, with
+// made-up checksums.
+func TestParseIndexUpstream(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "index", "upstream.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := parseIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := index.Keys()
+	sort.Strings(keys)
+	if got := strings.Join(keys, " "); got != "0.12.0 0.13.0 master" {
+		t.Errorf("versions %s", got)
+	}
+
+	master := index["master"]
+	if master.Version != "0.14.0-dev.2345+abcdef012" || master.Date != "2024-10-01" ||
+		master.Docs != "https://ziglang.org/documentation/master/" || master.StdDocs != "https://ziglang.org/documentation/master/std/" {
+		t.Errorf("master metadata %+v", master)
+	}
+	if master.Src == nil || master.Bootstrap == nil {
+		t.Error("master lacks src or bootstrap")
+	}
+	// Metadata and sources are not platforms
+	for _, key := range []string{"version", "date", "docs", "stdDocs", "notes", "src", "bootstrap"} {
+		for version, e := range index {
+			if _, ok := e.Artifacts[key]; ok {
+				t.Errorf("%s lists %s as an artifact", version, key)
+			}
+		}
+	}
+	if n := len(master.Artifacts); n != 5 {
+		t.Errorf("master has %d artifacts, want 5", n)
+	}
+
+	a := index["0.13.0"].Artifacts["x86_64-linux"]
+	if a.Tarball != "https://ziglang.org/download/0.13.0/zig-linux-x86_64-0.13.0.tar.xz" || a.Size != 47000000 || len(a.Shasum) != 64 {
+		t.Errorf("0.13.0 x86_64-linux = %+v", a)
+	}
+	if notes := index["0.13.0"].Notes; notes != "https://ziglang.org/download/0.13.0/release-notes.html" {
+		t.Errorf("0.13.0 notes %q", notes)
+	}
+}
+
+func TestParseIndexMalformed(t *testing.T) {
+	const sum = `"shasum": "0000000000000000000000000000000000000000000000000000000000000000"`
+	tests := []struct {
+		name, index, want string
+		// decoded is set for cases UnmarshalJSON itself rejects, without
+		// the shape check in front
+		decoded bool
+	}{
+		{"short shasum", `{"0.13.0": {"x86_64-linux": {"tarball": "zig.tar.xz", "shasum": "abc"}}}`, "expected 64 hex characters", true},
+		{"uppercase shasum", `{"0.13.0": {"x86_64-linux": {"tarball": "zig.tar.xz", "shasum": "` + strings.Repeat("A", 64) + `"}}}`, "expected lowercase hex", true},
+		{"non-hex shasum", `{"0.13.0": {"x86_64-linux": {"tarball": "zig.tar.xz", "shasum": "` + strings.Repeat("g", 64) + `"}}}`, "invalid shasum", true},
+		{"non-integer size", `{"0.13.0": {"x86_64-linux": {"tarball": "zig.tar.xz", ` + sum + `, "size": "12ab"}}}`, `invalid size "12ab"`, true},
+		{"negative size", `{"0.13.0": {"x86_64-linux": {"tarball": "zig.tar.xz", ` + sum + `, "size": "-1"}}}`, "invalid size -1", true},
+		{"boolean size", `{"0.13.0": {"x86_64-linux": {"tarball": "zig.tar.xz", ` + sum + `, "size": true}}}`, "size: expected string, got boolean", true},
+		{"missing tarball", `{"0.13.0": {"x86_64-linux": {` + sum + `}}}`, "tarball: missing", true},
+		{"numeric tarball", `{"0.13.0": {"x86_64-linux": {"tarball": 1, ` + sum + `}}}`, "tarball: expected string, got number", true},
+		{"numeric date", `{"0.13.0": {"date": 20240607}}`, `"0.13.0".date: expected string, got number`, true},
+		{"array version", `{"master": {"version": ["0.14.0"]}}`, `"master".version: expected string, got array`, true},
+		{"entry not an object", `{"0.13.0": "2024-06-07"}`, `"0.13.0": expected object, got string`, true},
+		{"bad CPU variant", `{"0.13.0": {"x86_64-linux": {"baseline": {"tarball": "zig.tar.xz", "shasum": "abc"}}}}`, "expected 64 hex characters", true},
+		{"HTML", "<!DOCTYPE html>\n<html><body>Not Found</body></html>", "index is HTML", false},
+		{"GitHub error", `{"message": "Not Found", "documentation_url": "https://docs.github.com/rest"}`, "GitHub API error", false},
+		{"truncated", `{"0.13.0": {"x86_64-linux": {"tarball": "zig.tar.xz", ` + sum, "truncated download?", false},
+		{"empty", "  \n", "index is empty", false},
+		{"array", `[]`, "expected an object of versions, got array", false},
+		{"no versions", `{}`, "index contains no versions", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseIndex([]byte(tt.index))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseIndex = %v, want an error containing %q", err, tt.want)
+			}
+			if !tt.decoded {
+				return
+			}
+			var index Index
+			if err := json.Unmarshal([]byte(tt.index), &index); err == nil {
+				t.Error("UnmarshalJSON accepted the index")
+			}
+		})
+	}
+}
+
+func TestResolveTarballURL(t *testing.T) {
+	tests := []struct {
+		tarball, base, want string
+	}{
+		{"zig-linux-x86_64-0.13.0.tar.xz", "https://mirror.example.com/zig/index.json", "https://mirror.example.com/zig/zig-linux-x86_64-0.13.0.tar.xz"},
+		{"0.13.0/zig.tar.xz", "https://mirror.example.com/zig/index.json", "https://mirror.example.com/zig/0.13.0/zig.tar.xz"},
+		{"../builds/zig.tar.xz", "https://mirror.example.com/zig/index.json", "https://mirror.example.com/builds/zig.tar.xz"},
+		{"/builds/zig.tar.xz", "https://mirror.example.com/zig/index.json", "https://mirror.example.com/builds/zig.tar.xz"},
+		{"zig.tar.xz?token=1", "https://mirror.example.com/zig/index.json?v=2", "https://mirror.example.com/zig/zig.tar.xz?token=1"},
+		{"zig.tar.xz", "http://127.0.0.1:8777/index.json", "http://127.0.0.1:8777/zig.tar.xz"},
+		// Absolute URLs and SSH sources stay as they are
+		{"https://ziglang.org/download/0.13.0/zig.tar.xz", "https://mirror.example.com/index.json", "https://ziglang.org/download/0.13.0/zig.tar.xz"},
+		{"builds:/srv/zig.tar.xz", "https://mirror.example.com/index.json", "builds:/srv/zig.tar.xz"},
+		// Relative to an index fetched over SSH
+		{"0.13.0/zig.tar.xz", "mirror:/srv/zig/index.json", "mirror:/srv/zig/0.13.0/zig.tar.xz"},
+		{"/srv/other/zig.tar.xz", "deploy@mirror:/srv/zig/index.json", "deploy@mirror:/srv/other/zig.tar.xz"},
+		{"zig.tar.xz", "sftp://mirror:2222/srv/zig/index.json", "sftp://mirror:2222/srv/zig/zig.tar.xz"},
+		// Nothing to resolve against, see TestResolveTarballsLocalIndex
+		{"zig.tar.xz", "/srv/zig/index.json", "zig.tar.xz"},
+		{"zig.tar.xz", "file:///srv/zig/index.json", "zig.tar.xz"},
+		{"", "https://mirror.example.com/index.json", ""},
+	}
+	for _, tt := range tests {
+		if got := resolveTarballURL(tt.tarball, tt.base); got != tt.want {
+			t.Errorf("resolveTarballURL(%q, %q) = %q, want %q", tt.tarball, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestResolveTarballs(t *testing.T) {
+	index, err := parseIndex([]byte(testIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.resolveTarballs("https://mirror.example.com/zig/index.json"); err != nil {
+		t.Fatal(err)
+	}
+	want := "https://mirror.example.com/zig/zig-linux-x86_64-0.13.0.tar.xz"
+	if got := index["0.13.0"].Artifacts["x86_64-linux"].Tarball; got != want {
+		t.Errorf("resolved tarball %s, want %s", got, want)
+	}
+}
+
+func TestResolveTarballsLocalIndex(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.json")
+	if err := os.WriteFile(path, []byte(testIndex), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{IndexFile: path, CacheDir: t.TempDir(), Logger: silentLogger{}}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = fetchIndexSources(cfg, client, indexFileURL(path))
+	if err == nil || !strings.Contains(err.Error(), "needs an http(s) or sftp index URL") {
+		t.Errorf("a relative tarball path in a local index gave %v", err)
+	}
+}
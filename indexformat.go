@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// indexParser decodes an index document into the internal Index.
+type indexParser func(data []byte) (Index, error)
+
+// indexFormats are the index layouts --index-format can select. Adding a
+// third-party layout means adding a parser here.
+var indexFormats = map[string]indexParser{
+	"ziglang": parseIndex,
+	"custom":  parseCustomIndex,
+}
+
+// indexParserFor returns the parser for format, "ziglang" when empty.
+func indexParserFor(format string) (indexParser, error) {
+	if format == "" {
+		format = "ziglang"
+	}
+	if parse, ok := indexFormats[format]; ok {
+		return parse, nil
+	}
+	names := make([]string, 0, len(indexFormats))
+	for name := range indexFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, newError(kindUsage, "unknown index format %q (expected %s)", format, strings.Join(names, " or "))
+}
+
+// customIndex is the list based layout used by some forks and distros:
+//
+//	{"releases": [{"key": "master", "version": "0.14.0-dev.1+abc",
+//	  "date": "...", "notes": "...", "docs": "...",
+//	  "artifacts": [{"platform": "x86_64-linux", "url": "...",
+//	                 "sha256": "...", "size": 123}]}]}
+//
+// key defaults to version.
+type customIndex struct {
+	Releases []struct {
+		Key       string `json:"key"`
+		Version   string `json:"version"`
+		Date      string `json:"date"`
+		Notes     string `json:"notes"`
+		Docs      string `json:"docs"`
+		Artifacts []struct {
+			Platform string `json:"platform"`
+			URL      string `json:"url"`
+			SHA256   string `json:"sha256"`
+			Size     int64  `json:"size"`
+		} `json:"artifacts"`
+	} `json:"releases"`
+}
+
+// parseCustomIndex maps the custom layout onto Index.
+func parseCustomIndex(data []byte) (Index, error) {
+	var raw customIndex
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %v", err)
+	}
+
+	index := make(Index, len(raw.Releases))
+	for i, r := range raw.Releases {
+		key := r.Key
+		if key == "" {
+			key = r.Version
+		}
+		if key == "" {
+			return nil, fmt.Errorf("failed to parse index: releases[%d]: missing version", i)
+		}
+		entry := VersionEntry{Date: r.Date, Notes: r.Notes, Docs: r.Docs, Artifacts: make(map[string]Artifact)}
+		if key != r.Version {
+			entry.Version = r.Version
+		}
+		for j, a := range r.Artifacts {
+			if a.Platform == "" || a.URL == "" {
+				return nil, fmt.Errorf("failed to parse index: releases[%d].artifacts[%d]: platform and url are required", i, j)
+			}
+			if err := validateShasum(a.SHA256); err != nil {
+				return nil, fmt.Errorf("failed to parse index: releases[%d].artifacts[%d]: %v", i, j, err)
+			}
+			if a.Size < 0 {
+				return nil, fmt.Errorf("failed to parse index: releases[%d].artifacts[%d]: invalid size %d", i, j, a.Size)
+			}
+			entry.Artifacts[a.Platform] = Artifact{Tarball: a.URL, Shasum: a.SHA256, Size: a.Size}
+		}
+		index[key] = entry
+	}
+	return index, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSchemaErrors is how many structural problems are reported at once.
+const maxSchemaErrors = 5
+
+// checkIndexShape validates the structure of a ziglang style index before
+// it is decoded, so a wrong --index-url yields errors with JSON paths
+// instead of "version not found" later on.
+func checkIndexShape(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return fmt.Errorf("index is empty")
+	}
+	if trimmed[0] == '<' {
+		return fmt.Errorf("index is HTML, not JSON; check that --index-url points at the index.json file rather than a web page")
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(trimmed, &doc); err != nil {
+		return fmt.Errorf("index is not valid JSON (truncated download?): %v", err)
+	}
+	top, ok := doc.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("index: expected an object of versions, got %s", jsonType(doc))
+	}
+	if msg, ok := top["message"].(string); ok && top["documentation_url"] != nil {
+		return fmt.Errorf("index is a GitHub API error (%q); check --index-url", msg)
+	}
+	if len(top) == 0 {
+		return fmt.Errorf("index contains no versions")
+	}
+
+	var problems []string
+	for _, version := range sortedKeys(top) {
+		problems = append(problems, checkVersionShape(version, top[version])...)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if len(problems) > maxSchemaErrors {
+		problems = append(problems[:maxSchemaErrors], fmt.Sprintf("... and %d more", len(problems)-maxSchemaErrors))
+	}
+	return fmt.Errorf("index does not look like a Zig download index:\n  %s", strings.Join(problems, "\n  "))
+}
+
+func checkVersionShape(version string, value interface{}) []string {
+	path := fmt.Sprintf("%q", version)
+	entry, ok := value.(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("%s: expected object, got %s", path, jsonType(value))}
+	}
+
+	var problems []string
+	for _, key := range sortedKeys(entry) {
+		field := entry[key]
+		if _, ok := metadataFields[key]; ok {
+			if _, ok := field.(string); !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s: expected string, got %s", path, key, jsonType(field)))
+			}
+			continue
+		}
+		artifact, ok := field.(map[string]interface{})
+		if !ok {
+			// Unknown scalar metadata is tolerated, see VersionEntry
+			continue
+		}
+		if variants, ok := cpuVariantShape(artifact); ok {
+			for _, name := range sortedKeys(variants) {
+				problems = append(problems, checkArtifactShape(path+"."+key+"."+name, variants[name].(map[string]interface{}))...)
+			}
+			continue
+		}
+		problems = append(problems, checkArtifactShape(path+"."+key, artifact)...)
+	}
+	return problems
+}
+
+// cpuVariantShape reports whether a platform value maps CPU variant names
+// to artifacts, the shape decodeVariants accepts.
+func cpuVariantShape(value map[string]interface{}) (map[string]interface{}, bool) {
+	if _, single := value["tarball"]; single || len(value) == 0 {
+		return nil, false
+	}
+	for _, v := range value {
+		if _, ok := v.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func checkArtifactShape(path string, artifact map[string]interface{}) []string {
+	var problems []string
+	for _, key := range []string{"tarball", "shasum"} {
+		switch v := artifact[key].(type) {
+		case string:
+			if key == "shasum" {
+				if err := validateShasum(v); err != nil {
+					problems = append(problems, fmt.Sprintf("%s.%s: %v", path, key, err))
+				}
+			}
+		case nil:
+			problems = append(problems, fmt.Sprintf("%s.%s: missing", path, key))
+		default:
+			problems = append(problems, fmt.Sprintf("%s.%s: expected string, got %s", path, key, jsonType(v)))
+		}
+	}
+	switch v := artifact["size"].(type) {
+	case nil, string, float64:
+	default:
+		problems = append(problems, fmt.Sprintf("%s.size: expected string, got %s", path, jsonType(v)))
+	}
+	return problems
+}
+
+// jsonType names the JSON type of a decoded value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
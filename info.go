@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// releaseInfo is the --json shape of the info command.
+type releaseInfo struct {
+	Key       string    `json:"key"`
+	Version   string    `json:"version"`
+	Date      string    `json:"date,omitempty"`
+	Docs      string    `json:"docs,omitempty"`
+	StdDocs   string    `json:"stdDocs,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+	Platform  string    `json:"platform"`
+	Artifact  *Artifact `json:"artifact,omitempty"`
+	Platforms []string  `json:"platforms"`
+	// CPUVariants names the builds of Platform when the index lists
+	// several; Artifact is the baseline one.
+	CPUVariants []string `json:"cpu_variants,omitempty"`
+}
+
+func runInfo(log *Logger, args []string) error {
+	var cfg Config
+	var platform string
+	fs := newCommandFlags("info", "info [flags] <version>", &cfg)
+	fs.StringVar(&platform, "platform", getPlatformKey(), "Platform key to show the artifact for")
+	positional, err := parseCommand(log, fs, &cfg, args)
+	if err != nil {
+		return err
+	}
+	requested := cfg.Version
+	if len(positional) > 0 {
+		requested = positional[0]
+	}
+
+	index, err := loadIndex(cfg, requested)
+	if err != nil {
+		return err
+	}
+	key, err := resolveVersion(index, requested, cfg.Prerelease)
+	if err != nil {
+		return err
+	}
+	entry := index[key]
+
+	info := releaseInfo{
+		Key:       key,
+		Version:   key,
+		Date:      entry.Date,
+		Docs:      entry.Docs,
+		StdDocs:   entry.StdDocs,
+		Notes:     entry.Notes,
+		Platform:  platform,
+		Platforms: entry.platforms(),
+	}
+	if entry.Version != "" {
+		info.Version = entry.Version
+	}
+	if a, ok := entry.artifact(platform); ok {
+		info.Artifact = &a
+	}
+	for name := range entry.Variants[entry.entryKey(platform)] {
+		info.CPUVariants = append(info.CPUVariants, name)
+	}
+	sort.Strings(info.CPUVariants)
+
+	if cfg.JSON {
+		return printJSON(info)
+	}
+
+	fmt.Printf("Zig %s\n", info.Key)
+	if info.Version != info.Key {
+		printField("version", info.Version)
+	}
+	printField("date", info.Date)
+	printField("notes", info.Notes)
+	printField("docs", info.Docs)
+	printField("std docs", info.StdDocs)
+	printField("platform", platform)
+	if info.Artifact == nil {
+		fmt.Printf("  (no artifact for %s)\n", platform)
+		return nil
+	}
+	printField("tarball", info.Artifact.Tarball)
+	printField("size", formatBytes(info.Artifact.Size))
+	printField("shasum", info.Artifact.Shasum)
+	printField("variants", strings.Join(info.CPUVariants, ", "))
+	return nil
+}
+
+func printField(name, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Printf("  %-10s %s\n", name+":", value)
+}
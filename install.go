@@ -0,0 +1,568 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// runInstall downloads, verifies and installs the version requested in cfg.
+func runInstall(cfg Config) error {
+	log := cfg.log()
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := checkDependencies(); err != nil {
+		return err
+	}
+	checkWSLStaging(cfg)
+	if cfg.BinName == "" || cfg.BinName != filepath.Base(cfg.BinName) || cfg.BinName == ".." {
+		return newError(kindUsage, "invalid --bin-name %q: must be a plain file name", cfg.BinName)
+	}
+	if cfg.KeepOld && cfg.BackupDir != "" {
+		return newError(kindUsage, "--keep-old cannot be combined with --backup-dir")
+	}
+	if err := checkDecompressor(cfg.Decompressor); err != nil {
+		return err
+	}
+	if err := awaitIndex(cfg, client); err != nil {
+		return err
+	}
+
+	// Work out what to install, from the index or a lockfile
+	var rel release
+	if cfg.FromLock != "" {
+		rel, err = lockedRelease(cfg, cfg.FromLock)
+		cfg.Version = rel.Version
+	} else {
+		rel, err = resolveRelease(cfg, client)
+	}
+	if err != nil {
+		return err
+	}
+	concrete, platformKey := rel.Version, rel.Platform
+	tarballURL, shasum := rel.Artifact.Tarball, rel.Artifact.Shasum
+	if err := crossCheckShasum(shasum, cfg.ExpectedSHA256); err != nil {
+		return err
+	}
+	if err := checkDownloadSize(rel.Artifact, cfg.MaxDownloadSize); err != nil {
+		return err
+	}
+	if cfg.ChecksumURL != "" && !rel.devBuild {
+		listed, err := fetchChecksum(client, cfg.ChecksumURL, path.Base(tarballURL))
+		if err != nil {
+			return newError(kindChecksum, "--checksum-url: %v", err)
+		}
+		if listed != shasum {
+			return newError(kindChecksum, "index shasum %s does not match %s from %s", shasum, listed, cfg.ChecksumURL)
+		}
+	}
+
+	// With --root everything lands below the staging root, but the
+	// manifest records the paths as seen from the target system
+	binDir, libDir := cfg.rooted(cfg.BinDir), cfg.rooted(cfg.LibDir)
+	libPath := filepath.Join(libDir, cfg.BinName)
+	log.Debug("resolved %s for %s to %s; tarball %s, extracting into %s, installing into %s and %s",
+		cfg.Version, platformKey, tarballURL, cfg.TarDest, cfg.Dest, filepath.Join(binDir, binaryFile(cfg.BinName, platformKey)), libPath)
+	if cfg.EmitScript {
+		fmt.Print(installScript(cfg, rel))
+		return nil
+	}
+	if cfg.Since && !cfg.Force && cfg.InstallTarballTo == "" && !cfg.DestOnly {
+		if installed, err := readManifest(manifestPath(libPath)); err == nil && !isNewerVersion(concrete, installed.Version) {
+			log.Success("Zig %s is already installed and %s has not moved, nothing to do", installed.Version, cfg.Version)
+			return reportInstall(cfg, installed, "up-to-date")
+		}
+	}
+
+	if !cfg.Force && cfg.InstallTarballTo == "" && !cfg.DestOnly {
+		if installed, err := readManifest(manifestPath(libPath)); err == nil && installed.Version == concrete && installed.Platform == platformKey {
+			if _, err := os.Stat(cfg.rooted(installed.BinPath)); err == nil {
+				log.Success("Zig %s is already installed, nothing to do (use --force to reinstall)", concrete)
+				return reportInstall(cfg, installed, "up-to-date")
+			}
+		}
+	}
+
+	// Check if we already have a valid tarball. Previous files are only
+	// reused without --clean or when asked to
+	needsDownload := true
+	if _, err := os.Stat(cfg.TarDest); err == nil && cfg.NoDownloadIfPresent {
+		log.Warning("using existing %s as-is, checksum verification skipped", cfg.TarDest)
+		needsDownload = false
+	} else if err == nil && !cfg.Clean {
+		log.Info("found existing file, checking checksum...")
+		if err := verifyChecksum(cfg.TarDest, shasum); err == nil {
+			log.Success("existing file matches checksum, skipping download")
+			needsDownload = false
+		} else {
+			log.Warning("existing file has incorrect checksum, will download fresh copy")
+		}
+	}
+
+	if cfg.DryRun {
+		return printPlan(cfg, planInstall(cfg, rel, needsDownload))
+	}
+
+	// Nothing has been touched yet, so declining leaves everything as it was
+	if !confirmInstall(cfg, rel, needsDownload) {
+		log.Info("install declined, nothing was changed")
+		return nil
+	}
+
+	steps := &stepCounter{log: log, total: installSteps(cfg, needsDownload)}
+
+	// Ensure parent directories exist
+	if err := ensureDirectoryExists(filepath.Dir(cfg.TarDest)); err != nil {
+		return newError(kindFilesystem, "failed to create tarball directory: %v", err)
+	}
+
+	// Clean up previous files
+	if needsDownload {
+		os.Remove(cfg.TarDest)
+	}
+	if err := prepareDest(cfg.Dest, cfg.Clean); err != nil {
+		return err
+	}
+
+	// Download tarball if needed
+	if needsDownload {
+		steps.step("downloading Zig %s for %s...", concrete, platformKey)
+		if err := downloadTarball(log, client, cfg.Mirrors, tarballURL, cfg.TarDest, cfg.MaxDownloadSize, cfg.Progress); err != nil {
+			if rel.devBuild {
+				return newError(kindNetwork, "failed to download dev build %s: %v (old dev builds are eventually removed from ziglang.org/builds)", concrete, err)
+			}
+			if cfg.GitHubFallback == "" || !isStableVersion(concrete) {
+				return newError(kindNetwork, "failed to download tarball: %v", err)
+			}
+			log.Warning("failed to download tarball: %v; trying GitHub release %s of %s", err, concrete, cfg.GitHubFallback)
+			if err := downloadFromGitHub(log, client, cfg.GitHubFallback, concrete, path.Base(tarballURL), shasum, cfg.TarDest, cfg.MaxDownloadSize, cfg.Progress); err != nil {
+				return err
+			}
+		}
+
+		// Verify checksum of downloaded file
+		steps.step("verifying checksum...")
+		if err := verifyChecksum(cfg.TarDest, shasum); err != nil {
+			os.Remove(cfg.TarDest)
+			return newError(kindChecksum, "checksum verification failed: %w", err)
+		}
+	}
+
+	if cfg.WriteLock != "" {
+		lock := Lockfile{Version: concrete, Platform: platformKey, TarballURL: tarballURL, Shasum: shasum, Size: rel.Artifact.Size}
+		if err := writeLock(cfg.WriteLock, lock); err != nil {
+			return newError(kindFilesystem, "failed to write lockfile: %v", err)
+		}
+		log.Info("wrote lockfile %s", cfg.WriteLock)
+	}
+
+	if cfg.InstallTarballTo != "" {
+		return placeTarball(cfg, steps, concrete, platformKey, tarballURL, shasum)
+	}
+
+	steps.step("extracting...")
+	if err := extractTarball(log, cfg.TarDest, cfg.Dest, cfg.StripComponents, cfg.Decompressor, cfg.Progress); err != nil {
+		return fmt.Errorf("failed to extract tarball: %w", err)
+	}
+	extractedBin, err := checkExtractedTree(log, cfg.Dest, platformKey)
+	if err != nil {
+		return err
+	}
+
+	if cfg.DestOnly {
+		if cfg.Clean {
+			os.Remove(cfg.TarDest)
+		}
+		log.Success("Zig %s for %s extracted to %s", concrete, platformKey, cfg.Dest)
+		if !cfg.JSON {
+			return nil
+		}
+		return printJSON(tarballResult{OK: true, Status: "extracted", Version: concrete, Platform: platformKey, Path: cfg.Dest, Shasum: shasum})
+	}
+
+	// Ensure installation directories exist
+	if err := ensureInstallDir(binDir, cfg.DirMode); err != nil {
+		return newError(kindFilesystem, "failed to create bin directory: %v", err)
+	}
+	if err := ensureInstallDir(libDir, cfg.DirMode); err != nil {
+		return newError(kindFilesystem, "failed to create lib directory: %v", err)
+	}
+
+	if err := checkReplaceable(libPath); err != nil {
+		return err
+	}
+	// The install goes into a directory named for the concrete version,
+	// which libPath links to
+	dir := versionDir(libDir, cfg.BinName, concrete)
+	if err := checkVersionDir(dir); err != nil {
+		return err
+	}
+
+	// Keep the previous install around if asked to
+	binPath := filepath.Join(binDir, binaryFile(cfg.BinName, platformKey))
+	var bak *backup
+	if cfg.BackupDir != "" {
+		bak, err = backupInstall(cfg.BackupDir, binPath, libPath)
+		if err != nil {
+			return newError(kindFilesystem, "failed to back up the previous install: %v", err)
+		}
+	} else if cfg.KeepOld {
+		bak, err = keepOld(binPath, libPath)
+		if err != nil {
+			return newError(kindFilesystem, "failed to keep the previous install: %v", err)
+		}
+	}
+	// rollback puts the backed up install back when installing fails
+	rollback := func(err error) error {
+		os.RemoveAll(dir)
+		if bak == nil {
+			return err
+		}
+		if restoreErr := bak.restore(binPath, libPath); restoreErr != nil {
+			log.Error("failed to restore the previous install from %s: %v", bak.dir, restoreErr)
+		} else {
+			log.Warning("restored the previous install")
+		}
+		return err
+	}
+
+	// Install zig
+	steps.step("installing...")
+	// The lib directory goes first, so the binary survives when it fails
+	if err := removeInstall(libPath); err != nil {
+		return rollback(removeFailedHint(libPath, err))
+	}
+	// Left behind by an install that did not finish
+	if err := os.RemoveAll(dir); err != nil {
+		return rollback(removeFailedHint(dir, err))
+	}
+	os.Remove(binPath)
+
+	if cfg.BinName == "zig" {
+		if err := os.Rename(extractedBin, binPath); err != nil {
+			return rollback(newError(kindFilesystem, "failed to install zig binary: %v", err))
+		}
+
+		// Move the entire lib directory
+		libSrcPath := filepath.Join(cfg.Dest, "lib")
+		if err := os.Rename(libSrcPath, dir); err != nil {
+			return rollback(newError(kindFilesystem, "failed to install zig libraries: %v", err))
+		}
+
+		// Set permissions explicitly instead of relying on the umask
+		if err := applyInstallModes(binPath, dir, cfg.FileMode, cfg.DirMode); err != nil {
+			return rollback(newError(kindFilesystem, "failed to set permissions: %v", err))
+		}
+	} else if err := installRenamed(cfg, platformKey, extractedBin, dir, binPath); err != nil {
+		return rollback(err)
+	}
+	if err := linkInstall(libPath, dir); err != nil {
+		return rollback(newError(kindFilesystem, "failed to link %s to %s: %v", libPath, dir, err))
+	}
+
+	// Record what we installed
+	manifest := Manifest{
+		Version:     concrete,
+		Requested:   cfg.Version,
+		Platform:    platformKey,
+		TarballURL:  tarballURL,
+		Shasum:      shasum,
+		BinPath:     filepath.Join(cfg.BinDir, binaryFile(cfg.BinName, platformKey)),
+		LibPath:     filepath.Join(cfg.LibDir, cfg.BinName),
+		Dir:         filepath.Join(cfg.LibDir, filepath.Base(resolveInstall(libPath))),
+		InstalledAt: now().UTC(),
+		Foreign:     !runsOnHost(platformKey),
+	}
+	steps.step("recording provenance...")
+	if manifest.Provenance, err = installProvenance(rel, binPath, libPath, manifest.InstalledAt); err != nil {
+		log.Warning("failed to record provenance: %v", err)
+	} else if cfg.Provenance != "" {
+		if err := writeProvenance(cfg.Provenance, *manifest.Provenance); err != nil {
+			return newError(kindFilesystem, "failed to write provenance: %v", err)
+		}
+		log.Info("wrote provenance %s", cfg.Provenance)
+	}
+	if err := writeManifest(manifestPath(libPath), manifest); err != nil {
+		log.Warning("failed to write manifest: %v", err)
+	}
+
+	// Cleanup
+	if cfg.Clean {
+		steps.step("cleaning up...")
+		os.Remove(cfg.TarDest)
+		if err := removeDest(cfg.Dest); err != nil {
+			log.Warning("%v", err)
+		}
+	}
+
+	log.Success("Zig %s installed successfully! 🎉", concrete)
+	// The console logger sums up how long each step took
+	if s, ok := log.(interface{ stepSummary() }); ok {
+		s.stepSummary()
+	}
+	switch {
+	case bak != nil && bak.dir != "":
+		log.Info("previous install backed up to %s", bak.dir)
+	case bak != nil:
+		log.Info("previous install kept as %s", strings.TrimSpace(bak.bin+" "+bak.lib))
+	}
+	postInstallChecks(cfg, manifest)
+
+	if cfg.InstallCompletions {
+		if err := installCompletions(log, cfg.InstallMan); err != nil {
+			log.Warning("%v", err)
+		}
+	}
+	return reportInstall(cfg, manifest, "installed")
+}
+
+// installRenamed installs under a --bin-name other than zig. Zig looks for
+// its lib directory next to the real path of its binary, and the default
+// lib location belongs to the regular install, so the binary and lib keep
+// the tarball layout inside the versioned directory dir and binPath links
+// to the binary.
+func installRenamed(cfg Config, platformKey, extractedBin, dir, binPath string) error {
+	if err := ensureInstallDir(dir, cfg.DirMode); err != nil {
+		return newError(kindFilesystem, "failed to create %s: %v", dir, err)
+	}
+	exe := binaryFile("zig", platformKey)
+	zigPath := filepath.Join(dir, exe)
+	if err := os.Rename(extractedBin, zigPath); err != nil {
+		return newError(kindFilesystem, "failed to install zig binary: %v", err)
+	}
+	if err := os.Rename(filepath.Join(cfg.Dest, "lib"), filepath.Join(dir, "lib")); err != nil {
+		return newError(kindFilesystem, "failed to install zig libraries: %v", err)
+	}
+	if err := applyInstallModes(zigPath, filepath.Join(dir, "lib"), cfg.FileMode, cfg.DirMode); err != nil {
+		return newError(kindFilesystem, "failed to set permissions: %v", err)
+	}
+
+	// A relative link keeps working below --root. It goes through the
+	// unversioned link, which the next install repoints
+	target, err := filepath.Rel(cfg.BinDir, filepath.Join(cfg.LibDir, cfg.BinName, exe))
+	if err != nil {
+		target = filepath.Join(cfg.LibDir, cfg.BinName, exe)
+	}
+	if err := os.Symlink(target, binPath); err != nil {
+		return newError(kindFilesystem, "failed to link %s: %v", binPath, err)
+	}
+	return nil
+}
+
+// release is a concrete artifact picked for installation.
+type release struct {
+	Version  string
+	Platform string
+	Artifact Artifact
+	IndexURL string
+	Lockfile string
+
+	// devBuild is set when the URL was built from --dev-url-template
+	// rather than taken from the index.
+	devBuild bool
+}
+
+// resolveRelease fetches the index for cfg.Version, resolves aliases and
+// ranges, and picks the artifact for the host platform.
+func resolveRelease(cfg Config, client *http.Client) (release, error) {
+	res, err := resolveIndexVersion(cfg, client)
+	if err != nil {
+		return release{}, err
+	}
+	platformKey, err := platformIn(cfg, res.index, res.key)
+	if err != nil {
+		return release{}, err
+	}
+	if err := checkPlatformAxes(cfg, res.index); err != nil {
+		return release{}, err
+	}
+	platformKey = pickARMVariant(cfg, res.index[res.key], platformKey)
+	return res.releaseFor(cfg, client, platformKey)
+}
+
+// resolvedVersion is cfg.Version looked up in the index, before a platform
+// is picked.
+type resolvedVersion struct {
+	index    Index
+	indexURL string
+	// sources are the index URLs after redirects, see fetchIndexSources.
+	sources string
+	key     string // index key, e.g. "master"
+	version string // concrete version
+
+	// dev is set for dev builds that are not in the index at all.
+	dev bool
+}
+
+// resolveIndexVersion fetches the index for cfg.Version and resolves
+// aliases and ranges to an index key.
+func resolveIndexVersion(cfg Config, client *http.Client) (resolvedVersion, error) {
+	indexURL, err := indexURLFor(cfg, cfg.Version)
+	if err != nil {
+		return resolvedVersion{}, err
+	}
+	log := cfg.log()
+	startTimedStep(log, "fetching index")
+	index, sources, err := fetchIndexSources(cfg, client, indexURL)
+	endTimedStep(log)
+	if err != nil {
+		return resolvedVersion{}, err
+	}
+
+	// Old dev builds drop out of the index but stay downloadable for a
+	// while, unless master still points at the requested one
+	if _, ok := index[cfg.Version]; !ok && isDevVersion(cfg.Version) {
+		if key := index.keyForVersion(cfg.Version); key != "" {
+			cfg.Version = key
+		} else {
+			return resolvedVersion{indexURL: indexURL, version: cfg.Version, dev: true}, nil
+		}
+	}
+
+	// Resolve aliases such as "stable" to a concrete index key
+	version, err := resolveVersion(index, cfg.Version, cfg.Prerelease)
+	if err != nil {
+		return resolvedVersion{}, err
+	}
+	if version != cfg.Version {
+		log.Info("resolved %s to %s", cfg.Version, version)
+	}
+
+	// Master entries carry the concrete dev version they point at
+	concrete := version
+	if v := index[version].Version; v != "" {
+		concrete = v
+	}
+	if concrete != version {
+		log.Info("%s is currently %s", version, concrete)
+	}
+	return resolvedVersion{index: index, indexURL: indexURL, sources: sources, key: version, version: concrete}, nil
+}
+
+// releaseFor picks the artifact of the resolved version for platformKey.
+func (r resolvedVersion) releaseFor(cfg Config, client *http.Client, platformKey string) (release, error) {
+	if r.dev {
+		rel, err := devRelease(cfg, client, r.version, platformKey)
+		rel.IndexURL = r.indexURL
+		return rel, err
+	}
+	artifact, err := r.index.artifactFor(cfg.log(), r.key, platformKey, cfg.CPUVariant)
+	if err != nil {
+		return release{}, err
+	}
+	if err := validateTarballURL(artifact.Tarball, r.sources, mirrorHosts(cfg.Mirrors), cfg.AllowForeignHost); err != nil {
+		return release{}, err
+	}
+	return release{Version: r.version, Platform: platformKey, Artifact: artifact, IndexURL: r.indexURL}, nil
+}
+
+// installSteps counts the steps runInstall announces, for numbering them.
+func installSteps(cfg Config, download bool) int {
+	n := 0
+	if download {
+		n += 2 // downloading, verifying
+	}
+	if cfg.InstallTarballTo != "" {
+		return n + 1 // placing
+	}
+	n++ // extracting
+	if cfg.DestOnly {
+		return n
+	}
+	n += 2 // installing, recording provenance
+	if cfg.Clean {
+		n++ // cleaning up
+	}
+	return n
+}
+
+// placeTarball moves the verified tarball into cfg.InstallTarballTo under
+// a name derived from the platform and version, for packaging workflows
+// that want the archive rather than an install.
+func placeTarball(cfg Config, steps *stepCounter, version, platformKey, tarballURL, shasum string) error {
+	log := cfg.log()
+	if err := ensureInstallDir(cfg.InstallTarballTo, cfg.DirMode); err != nil {
+		return newError(kindFilesystem, "failed to create %s: %v", cfg.InstallTarballTo, err)
+	}
+
+	ext := ".tar.xz"
+	if strings.HasSuffix(tarballURL, ".zip") {
+		ext = ".zip"
+	}
+	dest := filepath.Join(cfg.InstallTarballTo, fmt.Sprintf("zig-%s-%s%s", platformKey, version, ext))
+
+	steps.step("placing tarball at %s...", dest)
+	if err := moveFile(cfg.TarDest, dest); err != nil {
+		return newError(kindFilesystem, "failed to place tarball: %v", err)
+	}
+	log.Success("Zig %s tarball for %s placed at %s", version, platformKey, dest)
+
+	if !cfg.JSON {
+		return nil
+	}
+	return printJSON(tarballResult{OK: true, Status: "placed", Version: version, Platform: platformKey, Path: dest, Shasum: shasum})
+}
+
+// tarballResult is the --json document printed by --install-tarball-to.
+type tarballResult struct {
+	OK       bool   `json:"ok"`
+	Status   string `json:"status"`
+	Version  string `json:"version"`
+	Platform string `json:"platform"`
+	Path     string `json:"path"`
+	Shasum   string `json:"shasum"`
+}
+
+// moveFile renames src to dest, copying when they are on different
+// filesystems.
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest + ".tmp")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dest + ".tmp")
+		return err
+	}
+	if err := os.Rename(dest+".tmp", dest); err != nil {
+		os.Remove(dest + ".tmp")
+		return err
+	}
+	return os.Remove(src)
+}
+
+// installResult is the --json document printed when an install finishes.
+type installResult struct {
+	OK     bool   `json:"ok"`
+	Status string `json:"status"`
+	Manifest
+}
+
+// reportInstall prints the final install result when --json is set.
+func reportInstall(cfg Config, m Manifest, status string) error {
+	if !cfg.JSON {
+		return nil
+	}
+	return printJSON(installResult{OK: true, Status: status, Manifest: m})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testConfig returns the configuration args give, with every directory in
+// a temporary one and the index at indexURL.
+func testConfig(t *testing.T, indexURL string, args ...string) Config {
+	t.Helper()
+	root := t.TempDir()
+	var cfg Config
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs, &cfg)
+	args = append([]string{
+		"-y",
+		"-index-url", indexURL,
+		"-bin-dir", filepath.Join(root, "bin"),
+		"-lib-dir", filepath.Join(root, "lib"),
+		"-tar-dest", filepath.Join(root, "work", "zig.tar.gz"),
+		"-dest", filepath.Join(root, "work", "zig"),
+		"-cache-dir", filepath.Join(root, "cache"),
+	}, args...)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// fakeZig is a shell script standing in for the zig binary of version,
+// padded to pass the size check of checkExtractedTree.
+func fakeZig(version string) string {
+	script := "#!/bin/sh\necho " + version + "\nexit 0\n"
+	return script + strings.Repeat("#"+strings.Repeat("x", 99)+"\n", minZigBinarySize/100+1)
+}
+
+// serveReleases serves an index with a release of the host platform for
+// each key of versions, which maps index keys to concrete versions, and
+// the tarballs it points at.
+func serveReleases(t *testing.T, versions map[string]string) *httptest.Server {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake zig binary is a shell script")
+	}
+	platform := getPlatformKey()
+	dir := t.TempDir()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	index := map[string]map[string]interface{}{}
+	for key, version := range versions {
+		top := "zig-" + platform + "-" + version
+		tarball := writeTarGz(t, t.TempDir(), map[string]string{
+			top + "/zig":                 fakeZig(version),
+			top + "/lib/std/std.zig":     "",
+			top + "/lib/std/mem.zig":     "",
+			top + "/doc/langref.html":    "",
+			top + "/LICENSE":             "",
+			top + "/lib/compiler_rt.zig": "",
+		})
+		data, err := os.ReadFile(tarball)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := top + ".tar.gz"
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		entry := map[string]interface{}{
+			"date": "2024-06-07",
+			platform: map[string]string{
+				"tarball": srv.URL + "/" + name,
+				"shasum":  hex.EncodeToString(sum[:]),
+				"size":    "1",
+			},
+		}
+		if key != version {
+			entry["version"] = version
+		}
+		index[key] = entry
+	}
+	indexData, err := json.Marshal(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/index.json", func(w http.ResponseWriter, r *http.Request) { w.Write(indexData) })
+	mux.Handle("/", http.FileServer(http.Dir(dir)))
+	return srv
+}
+
+func TestInstallMasterNamesConcreteVersion(t *testing.T) {
+	srv := serveReleases(t, map[string]string{"master": "0.14.0-dev.2345+abcdef012"})
+	cfg := testConfig(t, srv.URL+"/index.json", "-version", "master")
+	rec := &recordingLogger{}
+	cfg.Logger = rec
+
+	if err := runInstall(cfg); err != nil {
+		t.Fatal(err)
+	}
+	libPath := filepath.Join(cfg.LibDir, "zig")
+	want := filepath.Join(cfg.LibDir, "zig-0.14.0-dev.2345+abcdef012")
+	if got := resolveInstall(libPath); got != want {
+		t.Errorf("installed into %s, want %s", got, want)
+	}
+	m, err := readManifest(manifestPath(libPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != "0.14.0-dev.2345+abcdef012" || m.Requested != "master" || m.Dir != want {
+		t.Errorf("manifest records version %s, requested %s, dir %s", m.Version, m.Requested, m.Dir)
+	}
+	if got := rec.Messages("success"); !containsMessage(got, "Zig 0.14.0-dev.2345+abcdef012 installed successfully") {
+		t.Errorf("success messages %q lack the concrete version", got)
+	}
+
+	// The same dev build is not installed again
+	rec = &recordingLogger{}
+	cfg.Logger = rec
+	if err := runInstall(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Messages("success"); !containsMessage(got, "Zig 0.14.0-dev.2345+abcdef012 is already installed") {
+		t.Errorf("success messages %q, want the install skipped", got)
+	}
+}
+
+func TestInstallReplacesVersionedDirectory(t *testing.T) {
+	srv := serveReleases(t, map[string]string{"0.12.0": "0.12.0", "0.13.0": "0.13.0"})
+	cfg := testConfig(t, srv.URL+"/index.json", "-version", "0.12.0")
+	cfg.Logger = silentLogger{}
+	if err := runInstall(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Version = "0.13.0"
+	if err := runInstall(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(cfg.LibDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, " "); got != "zig zig-0.13.0" {
+		t.Errorf("lib dir holds %s, want zig zig-0.13.0", got)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.LibDir, "zig", "std", "std.zig")); err != nil {
+		t.Errorf("the lib tree is not reachable through the link: %v", err)
+	}
+	if got, err := zigVersionOf(filepath.Join(cfg.BinDir, "zig")); err != nil || got != "0.13.0" {
+		t.Errorf("installed binary reports %q, %v", got, err)
+	}
+}
+
+func TestPlanInstallListsVersionedDirectories(t *testing.T) {
+	cfg := testConfig(t, "http://127.0.0.1/index.json", "-version", "0.13.0")
+	current := fakeInstall(t, cfg.LibDir, "zig", "0.12.0")
+	// Left behind by an install that did not finish
+	leftover := versionDir(cfg.LibDir, "zig", "0.13.0")
+	os.MkdirAll(leftover, 0755)
+	rel := release{Version: "0.13.0", Platform: getPlatformKey(), Artifact: Artifact{Tarball: "https://ziglang.org/zig.tar.xz"}}
+
+	p := planInstall(cfg, rel, false)
+	if p.Lib != leftover {
+		t.Errorf("lib = %s, want %s", p.Lib, leftover)
+	}
+	want := []string{filepath.Join(cfg.LibDir, "zig"), current, leftover}
+	if got := strings.Join(p.Remove, " "); got != strings.Join(want, " ") {
+		t.Errorf("remove = %v, want %v", p.Remove, want)
+	}
+
+	cfg.KeepOld = true
+	p = planInstall(cfg, rel, false)
+	if got := strings.Join(p.Remove, " "); got != leftover {
+		t.Errorf("remove with --keep-old = %v, want [%s]", p.Remove, leftover)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// InstalledVersion is a managed Zig install found below a lib directory.
+type InstalledVersion struct {
+	Version     string    `json:"version"`
+	Requested   string    `json:"requested"`
+	Platform    string    `json:"platform"`
+	Name        string    `json:"name"`
+	BinPath     string    `json:"bin_path"`
+	LibPath     string    `json:"lib_path"`
+	Dir         string    `json:"dir,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+	// Active is set for the install that plain "zig" runs, as opposed to
+	// ones installed under another --bin-name.
+	Active bool `json:"active"`
+}
+
+// ListInstalled reports the installs recorded by manifests below libDir,
+// newest version first.
+func ListInstalled(libDir string) ([]InstalledVersion, error) {
+	manifests, err := listInstalled(libDir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]InstalledVersion, 0, len(manifests))
+	for _, m := range manifests {
+		name := filepath.Base(m.LibPath)
+		out = append(out, InstalledVersion{
+			Version:     m.Version,
+			Requested:   m.Requested,
+			Platform:    m.Platform,
+			Name:        name,
+			BinPath:     m.BinPath,
+			LibPath:     m.LibPath,
+			Dir:         m.Dir,
+			InstalledAt: m.InstalledAt,
+			Active:      name == "zig",
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return isNewerVersion(out[i].Version, out[j].Version) })
+	return out, nil
+}
+
+// printInstalled renders ListInstalled for --list-installed.
+func printInstalled(cfg Config) error {
+	installed, err := ListInstalled(cfg.rooted(cfg.LibDir))
+	if err != nil {
+		return newError(kindFilesystem, "failed to list installs: %v", err)
+	}
+	if cfg.JSON {
+		return printJSON(installed)
+	}
+	if len(installed) == 0 {
+		cfg.log().Info("no installs found in %s", cfg.LibDir)
+		return nil
+	}
+	for _, v := range installed {
+		marker := " "
+		if v.Active {
+			marker = "*"
+		}
+		fmt.Printf("%s %-28s %-12s %s  %s\n", marker, v.Version, v.Name, v.InstalledAt.Local().Format("2006-01-02 15:04"), v.BinPath)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// captureOutput returns what fn writes to *f, os.Stdout or os.Stderr.
+func captureOutput(t *testing.T, f **os.File, fn func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := *f
+	*f = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	defer func() { *f = saved }()
+	fn()
+	w.Close()
+	return <-done
+}
+
+// checkGolden compares got with testdata/name, or rewrites it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// testManifest is an install of 0.13.0 with every field set.
+var testManifest = Manifest{
+	Version:     "0.13.0",
+	Requested:   "stable",
+	Platform:    "x86_64-linux",
+	TarballURL:  "https://ziglang.org/download/0.13.0/zig-linux-x86_64-0.13.0.tar.xz",
+	Shasum:      "d45312e61ebcc48032b77bc4cf7fd6915c11fa16e4aad116b66c9468211230ea",
+	BinPath:     "/usr/local/bin/zig",
+	LibPath:     "/usr/local/lib/zig",
+	Dir:         "/usr/local/lib/zig-0.13.0",
+	InstalledAt: time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC),
+}
+
+func TestJSONSchemas(t *testing.T) {
+	index, err := parseIndex([]byte(testIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		golden string
+		doc    interface{}
+	}{
+		{"list.json", remoteVersions(index, "ziglang", "x86_64-linux")},
+		{"info.json", releaseInfo{
+			Key:       "0.13.0",
+			Version:   "0.13.0",
+			Date:      "2024-06-07",
+			Platform:  "x86_64-linux",
+			Artifact:  &Artifact{Tarball: "https://ziglang.org/download/0.13.0/zig-linux-x86_64-0.13.0.tar.xz", Shasum: testManifest.Shasum, Size: 47082308},
+			Platforms: []string{"aarch64-linux", "x86_64-linux"},
+		}},
+		{"status.json", statusOutput{Manifest: testManifest, Running: "0.13.0"}},
+		{"which.json", whichOutput{Path: "/usr/local/bin/zig", Installed: true, Version: "0.13.0", OnPath: "/usr/bin/zig"}},
+		{"outdated.json", []outdatedEntry{{Installed: "0.12.0", Latest: "0.13.0", Channel: "stable", LibPath: "/usr/local/lib/zig", Active: true, Outdated: true}}},
+		{"installed.json", []InstalledVersion{{Version: "0.13.0", Requested: "stable", Platform: "x86_64-linux", Name: "zig", BinPath: "/usr/local/bin/zig", LibPath: "/usr/local/lib/zig", Dir: "/usr/local/lib/zig-0.13.0", InstalledAt: testManifest.InstalledAt, Active: true}}},
+		{"install.json", installResult{OK: true, Status: "installed", Manifest: testManifest}},
+		{"tarball.json", tarballResult{OK: true, Status: "placed", Version: "0.13.0", Platform: "x86_64-linux", Path: "/srv/zig-x86_64-linux-0.13.0.tar.xz", Shasum: testManifest.Shasum}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			out := captureOutput(t, &os.Stdout, func() {
+				if err := printJSON(tt.doc); err != nil {
+					t.Fatal(err)
+				}
+			})
+			checkGolden(t, filepath.Join("json", tt.golden), out)
+		})
+	}
+}
+
+func TestJSONErrorSchema(t *testing.T) {
+	out := captureOutput(t, &os.Stdout, func() {
+		printJSONError(newError(kindNotFound, "version 0.99.0 not found in index"))
+	})
+	checkGolden(t, filepath.Join("json", "error.json"), out)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// hostLibc returns the C library flavor of a Linux host, "musl" or
+// "glibc", judged by which dynamic loader is installed. It returns ""
+// elsewhere or when neither loader is found.
+func hostLibc() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	if m, _ := filepath.Glob("/lib/ld-musl-*.so.1"); len(m) > 0 {
+		return "musl"
+	}
+	for _, pattern := range []string{"/lib64/ld-linux*.so.*", "/lib/ld-linux*.so.*", "/lib/*-linux-gnu*/ld-linux*.so.*"} {
+		if m, _ := filepath.Glob(pattern); len(m) > 0 {
+			return "glibc"
+		}
+	}
+	return ""
+}
+
+// libcFlavor tells the C library a dynamic loader path belongs to.
+func libcFlavor(interp string) string {
+	switch base := filepath.Base(interp); {
+	case strings.HasPrefix(base, "ld-musl"):
+		return "musl"
+	case strings.HasPrefix(base, "ld-linux"):
+		return "glibc"
+	}
+	return "an unknown libc"
+}
+
+// elfInterpreter returns the dynamic loader an ELF binary asks for, or ""
+// for a static binary.
+func elfInterpreter(path string) (string, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_INTERP {
+			continue
+		}
+		data, err := io.ReadAll(p.Open())
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\x00"), nil
+	}
+	return "", nil
+}
+
+// diagnoseRunFailure explains a failure to run the installed binary at
+// bin when it comes down to the C library: the kernel reports a binary
+// that exists as not found when its dynamic loader is missing, and the
+// loader itself fails on missing shared libraries. It returns "" when the
+// failure has nothing to do with either.
+func diagnoseRunFailure(bin string, runErr error) string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	var stderr string
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		stderr = string(exitErr.Stderr)
+	}
+	loaderError := strings.Contains(stderr, "error while loading shared libraries") ||
+		strings.Contains(stderr, "Error loading shared library") ||
+		strings.Contains(stderr, "Error relocating")
+	if !errors.Is(runErr, syscall.ENOENT) && !loaderError {
+		return ""
+	}
+	if _, err := os.Stat(bin); err != nil {
+		return ""
+	}
+
+	interp, err := elfInterpreter(bin)
+	if err != nil {
+		return ""
+	}
+	host := hostLibc()
+	if host == "" {
+		host = "unknown"
+	}
+	switch {
+	case interp == "":
+		return fmt.Sprintf("the binary is statically linked, so the failure is not caused by the host's C library (%s)", host)
+	case loaderError:
+		return fmt.Sprintf("the binary is linked against %s through %s and is missing shared libraries on this %s host: %s",
+			libcFlavor(interp), interp, host, strings.TrimSpace(stderr))
+	}
+	if _, err := os.Stat(interp); err == nil {
+		return ""
+	}
+	return fmt.Sprintf("the binary needs the %s dynamic loader %s, which this host (libc: %s) does not have; the file exists, the kernel reports the missing loader as \"not found\". Install a build for this libc or a %s compatibility package",
+		libcFlavor(interp), interp, host, libcFlavor(interp))
+}
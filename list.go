@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// remoteVersion is a single line of the list command.
+type remoteVersion struct {
+	Key     string `json:"key"`
+	Version string `json:"version"`
+	Date    string `json:"date,omitempty"`
+	Source  string `json:"source"`
+	// Available reports whether the version has an artifact for the
+	// platform list was asked about.
+	Available bool `json:"available"`
+}
+
+func runList(log *Logger, args []string) error {
+	var cfg Config
+	var mach, showMissing bool
+	var platform string
+	fs := newCommandFlags("list", "list [flags]", &cfg)
+	fs.BoolVar(&mach, "mach", false, "Also list Mach nominated versions")
+	fs.StringVar(&platform, "platform", getPlatformKey(), "Only list versions with an artifact for this platform key")
+	fs.BoolVar(&showMissing, "show-missing", false, "Also list versions without an artifact for --platform, marked as missing")
+	if _, err := parseCommand(log, fs, &cfg, args); err != nil {
+		return err
+	}
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	url, err := indexURLFor(cfg, cfg.Version)
+	if err != nil {
+		return err
+	}
+	index, err := fetchIndexCached(cfg, client, url)
+	if err != nil {
+		return err
+	}
+	versions := remoteVersions(index, "ziglang", platform)
+	// With merged indexes, only versions from the extra ones are annotated
+	primary := url
+	if urls := splitList(url); len(urls) > 0 {
+		primary = urls[0]
+	}
+
+	if mach && url != cfg.MachIndexURL {
+		machIndex, err := fetchIndexCached(cfg, client, cfg.MachIndexURL)
+		if err != nil {
+			return err
+		}
+		versions = append(versions, remoteVersions(machIndex, "mach", platform)...)
+	}
+
+	if !showMissing {
+		available := versions[:0]
+		for _, v := range versions {
+			if v.Available {
+				available = append(available, v)
+			}
+		}
+		versions = available
+	}
+
+	if cfg.JSON {
+		return printJSON(versions)
+	}
+
+	if len(versions) == 0 {
+		cfg.log().Info("no versions with a %s build (use --show-missing to list them anyway)", platform)
+		return nil
+	}
+
+	dim, reset := "", ""
+	if l, ok := cfg.log().(consoleLogger); ok && l.colorOut {
+		dim, reset = "\033[2m", "\033[0m"
+	}
+	for _, v := range versions {
+		line := fmt.Sprintf("%-24s %-12s", v.Key, v.Date)
+		if v.Version != v.Key {
+			line += " " + v.Version
+		}
+		if v.Source != "ziglang" && v.Source != primary {
+			line += " (" + v.Source + ")"
+		}
+		line = strings.TrimRight(line, " ")
+		if !v.Available {
+			line = dim + line + " [no " + platform + " build]" + reset
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// remoteVersions flattens an index into list entries: non-version keys
+// such as "master" first, then versions newest first. Each entry notes
+// whether it ships an artifact for platform.
+func remoteVersions(index Index, source, platform string) []remoteVersion {
+	var named []string
+	var parsed []string
+	for k := range index {
+		if _, err := parseVersion(k); err != nil {
+			named = append(named, k)
+			continue
+		}
+		parsed = append(parsed, k)
+	}
+	sort.Strings(named)
+
+	keys := named
+	for _, v := range sortedVersions(parsed) {
+		keys = append(keys, v.String())
+	}
+
+	out := make([]remoteVersion, 0, len(keys))
+	for _, k := range keys {
+		entry := index[k]
+		_, available := entry.artifact(platform)
+		v := remoteVersion{Key: k, Version: k, Date: entry.Date, Source: source, Available: available}
+		if entry.Origin != "" {
+			v.Source = entry.Origin
+		}
+		if entry.Version != "" {
+			v.Version = entry.Version
+		}
+		out = append(out, v)
+	}
+	return out
+}
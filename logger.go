@@ -0,0 +1,525 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Logger is where the install flow reports what it is doing. The CLI
+// logs to the console; a program embedding the installer passes its own
+// in Config.Logger, such as a silentLogger or a recordingLogger.
+type Logger interface {
+	Info(format string, a ...interface{})
+	Success(format string, a ...interface{})
+	Warning(format string, a ...interface{})
+	Error(format string, a ...interface{})
+	// Step announces the next step of a longer operation.
+	Step(format string, a ...interface{})
+	Debug(format string, a ...interface{})
+	// Progress returns the ProgressFunc downloads and extraction report
+	// to, or nil to not follow them.
+	Progress() ProgressFunc
+}
+
+// consoleLogger is the Logger of the CLI. It writes prose or, with
+// --log-format json, NDJSON events to stdout and stderr, and draws
+// progress bars and spinners on terminals.
+type consoleLogger struct {
+	level logLevel
+	// steps times the steps of a run and draws their spinner, see
+	// startStep. It is a pointer so the value receivers share it.
+	steps *stepTimer
+	// json turns log lines into NDJSON events, see --log-format.
+	json bool
+	// file receives every log line for --log-file, or is nil.
+	file io.Writer
+	// results is set when stdout is reserved for --json documents, so a
+	// failure ends with one there too.
+	results bool
+	// out receives everything but errors. It is stdout unless stdout is
+	// reserved for --json documents, --emit-script or the exports of
+	// --ephemeral, or logs are JSON.
+	out io.Writer
+	// styles are the prefixes of the levels, see logStyles.
+	styles map[string]logStyle
+	// ascii replaces the few symbols used in messages, see --ascii.
+	ascii bool
+	// timestamps prefixes lines with the time or the time since started,
+	// see --timestamps.
+	timestamps string
+	started    time.Time
+	// colorOut and colorErr enable colored prefixes on out and on
+	// stderr, which are decided separately, see --color.
+	colorOut bool
+	colorErr bool
+
+	colorReset  string
+	colorRed    string
+	colorGreen  string
+	colorYellow string
+	colorBlue   string
+	colorCyan   string
+}
+
+// logLevel is how much the logger prints, from nothing to debug output.
+type logLevel int
+
+const (
+	// levelSilent prints nothing, for check-update --quiet.
+	levelSilent logLevel = iota
+	// levelQuiet prints errors only, see --quiet.
+	levelQuiet
+	levelNormal
+	// levelVerbose adds debug output, see --verbose.
+	levelVerbose
+)
+
+// logStyle is how a level is introduced: an icon followed by a label,
+// which is colored.
+type logStyle struct {
+	icon  string
+	label string
+}
+
+var emojiStyles = map[string]logStyle{
+	"info":    {"💡 ", "info:"},
+	"success": {"✅ ", "success:"},
+	"warning": {"⚠️  ", "warning:"},
+	"error":   {"❌ ", "error:"},
+	"debug":   {"🔍 ", "debug:"},
+	"step":    {"👉 ", "step:"},
+}
+
+// asciiStyles are used with --ascii, for terminals, log collectors and
+// screen readers that do badly with emoji.
+var asciiStyles = map[string]logStyle{
+	"info":    {"", "[info]"},
+	"success": {"", "[ok]"},
+	"warning": {"", "[warn]"},
+	"error":   {"", "[error]"},
+	"debug":   {"", "[debug]"},
+	"step":    {"", "[step]"},
+}
+
+// asciiReplacer spells out the symbols messages use in plain ASCII.
+var asciiReplacer = strings.NewReplacer(" 🎉", "", "→", "->", "—", "-")
+
+// logStyles picks the prefix of every level: emoji or, with ascii, plain
+// tags, each replaced by its entry in prefixes if there is one.
+func logStyles(ascii bool, prefixes map[string]string) map[string]logStyle {
+	styles := make(map[string]logStyle, len(emojiStyles))
+	for level, style := range emojiStyles {
+		if ascii {
+			style = asciiStyles[level]
+		}
+		if prefix, ok := prefixes[level]; ok {
+			style = logStyle{label: prefix}
+		}
+		styles[level] = style
+	}
+	return styles
+}
+
+// unicodeTerminal reports whether the environment promises to render
+// emoji: TERM is not dumb and, outside Windows, the locale is UTF-8.
+// --ascii defaults to the opposite.
+func unicodeTerminal() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			locale = strings.ToLower(locale)
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return false
+}
+
+// parseLogPrefixes parses --log-prefixes, comma separated level=prefix
+// pairs such as "info=I,error=E!".
+func parseLogPrefixes(s string) (map[string]string, error) {
+	prefixes := make(map[string]string)
+	for _, pair := range splitList(s) {
+		level, prefix, ok := strings.Cut(pair, "=")
+		if _, known := emojiStyles[level]; !ok || !known {
+			return nil, fmt.Errorf("invalid log prefix %q: expected level=prefix with level one of info, success, warning, error, debug, step", pair)
+		}
+		prefixes[level] = prefix
+	}
+	return prefixes, nil
+}
+
+// Values of --color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// parseColorMode validates a --color value.
+func parseColorMode(s string) (string, error) {
+	switch s {
+	case colorAuto, colorAlways, colorNever:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid color mode %q: expected auto, always or never", s)
+}
+
+// colorEnabled decides whether output to w is colored under mode. auto
+// colors terminals only, and never when NO_COLOR is set or TERM is dumb.
+func colorEnabled(mode string, w io.Writer) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// paint returns color when lines written to w are colored, else "".
+func (l consoleLogger) paint(w io.Writer, color string) string {
+	if w == os.Stderr && l.colorErr || w != os.Stderr && l.colorOut {
+		return color
+	}
+	return ""
+}
+
+// prefix renders the prefix of level in color.
+func (l consoleLogger) prefix(level, color string) string {
+	style := l.styles[level]
+	if color == "" || style.label == "" {
+		return style.icon + style.label
+	}
+	return style.icon + color + style.label + l.colorReset
+}
+
+func (l consoleLogger) info(format string, a ...interface{}) {
+	l.record("info", format, a...)
+	if l.level < levelNormal {
+		return
+	}
+	l.log(l.out, "info", l.prefix("info", l.paint(l.out, l.colorBlue)), format, a...)
+}
+
+func (l consoleLogger) success(format string, a ...interface{}) {
+	l.endStep()
+	l.record("success", format, a...)
+	if l.level < levelNormal {
+		return
+	}
+	l.log(l.out, "success", l.prefix("success", l.paint(l.out, l.colorGreen)), format, a...)
+}
+
+func (l consoleLogger) warning(format string, a ...interface{}) {
+	l.record("warning", format, a...)
+	if l.level < levelNormal {
+		return
+	}
+	l.log(l.out, "warning", l.prefix("warning", l.paint(l.out, l.colorYellow)), format, a...)
+}
+
+func (l consoleLogger) error(format string, a ...interface{}) {
+	l.stopSpinner()
+	l.record("error", format, a...)
+	if l.level < levelQuiet {
+		return
+	}
+	l.log(os.Stderr, "error", l.prefix("error", l.paint(os.Stderr, l.colorRed)), format, a...)
+}
+
+func (l consoleLogger) Info(format string, a ...interface{})    { l.info(format, a...) }
+func (l consoleLogger) Success(format string, a ...interface{}) { l.success(format, a...) }
+func (l consoleLogger) Warning(format string, a ...interface{}) { l.warning(format, a...) }
+func (l consoleLogger) Error(format string, a ...interface{})   { l.error(format, a...) }
+func (l consoleLogger) Step(format string, a ...interface{})    { l.step(format, a...) }
+func (l consoleLogger) Debug(format string, a ...interface{})   { l.debug(format, a...) }
+func (l consoleLogger) Progress() ProgressFunc                  { return l.progressBar() }
+
+// debug logs details that are only interesting when diagnosing a problem.
+// It is only shown with --verbose.
+func (l consoleLogger) debug(format string, a ...interface{}) {
+	l.record("debug", format, a...)
+	if l.level < levelVerbose {
+		return
+	}
+	l.log(l.out, "debug", l.prefix("debug", ""), format, a...)
+}
+
+// step announces the next step and times it, see startStep.
+func (l consoleLogger) step(format string, a ...interface{}) {
+	l.endStep()
+	l.record("step", format, a...)
+	if l.level >= levelNormal {
+		l.log(l.out, "step", l.prefix("step", l.paint(l.out, l.colorCyan)), format, a...)
+	}
+	l.startStep(stepNumber.ReplaceAllString(strings.TrimSuffix(fmt.Sprintf(format, a...), "..."), ""))
+}
+
+// Values of --log-format.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// parseLogFormat validates a --log-format value.
+func parseLogFormat(s string) (string, error) {
+	switch s {
+	case logFormatText, logFormatJSON:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid log format %q: expected text or json", s)
+}
+
+// Values of --timestamps.
+const (
+	timestampsRFC3339  = "rfc3339"
+	timestampsRelative = "relative"
+)
+
+// timestampsFlag is the flag.Value of --timestamps. Given without a value
+// it picks RFC 3339 timestamps.
+type timestampsFlag struct {
+	mode *string
+}
+
+func (f timestampsFlag) String() string {
+	if f.mode == nil {
+		return ""
+	}
+	return *f.mode
+}
+
+func (f timestampsFlag) Set(s string) error {
+	mode, err := parseTimestamps(s)
+	if err != nil {
+		return err
+	}
+	*f.mode = mode
+	return nil
+}
+
+func (f timestampsFlag) IsBoolFlag() bool { return true }
+
+// parseTimestamps validates a --timestamps value, "" being off.
+func parseTimestamps(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "", "false", "off":
+		return "", nil
+	case "true", "on", timestampsRFC3339:
+		return timestampsRFC3339, nil
+	case timestampsRelative:
+		return timestampsRelative, nil
+	}
+	return "", fmt.Errorf("invalid timestamps %q: expected rfc3339 or relative", s)
+}
+
+// stamp renders the --timestamps prefix of a line, or "". Both kinds are
+// pinned in --deterministic runs like every other time.
+func (l consoleLogger) stamp() string {
+	switch l.timestamps {
+	case timestampsRFC3339:
+		return now().UTC().Format(time.RFC3339) + " "
+	case timestampsRelative:
+		return fmt.Sprintf("+%.1fs ", now().Sub(l.started).Seconds())
+	}
+	return ""
+}
+
+// logEvent is one line of the NDJSON log written with --log-format json.
+// Bytes and Total are only set on progress events, and Elapsed with
+// --timestamps relative.
+type logEvent struct {
+	Level   string `json:"level"`
+	Message string `json:"msg"`
+	Time    string `json:"ts"`
+	Elapsed string `json:"elapsed,omitempty"`
+	Step    string `json:"step,omitempty"`
+	Bytes   *int64 `json:"bytes,omitempty"`
+	Total   *int64 `json:"total,omitempty"`
+}
+
+// emit writes ev to w as one NDJSON line, stamped with the time and the
+// step in progress.
+func (l consoleLogger) emit(w io.Writer, ev logEvent) {
+	ev.Time = now().UTC().Format(time.RFC3339)
+	if l.timestamps == timestampsRelative {
+		ev.Elapsed = strings.TrimSpace(l.stamp())
+	}
+	if l.steps != nil {
+		ev.Step = l.steps.current()
+	}
+	data, _ := json.Marshal(ev)
+	fmt.Fprintf(w, "%s\n", data)
+}
+
+// log writes one message to w, as prose with the given prefix or, with
+// --log-format json, as an NDJSON event.
+func (l consoleLogger) log(w io.Writer, level, prefix, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	if l.ascii {
+		msg = asciiReplacer.Replace(msg)
+	}
+	if l.json {
+		l.emit(w, logEvent{Level: level, Message: msg})
+		return
+	}
+	if l.steps != nil {
+		// Take the line from the spinner, which redraws below
+		l.steps.mu.Lock()
+		defer l.steps.mu.Unlock()
+		l.steps.clearLine()
+	}
+	if prefix == "" {
+		fmt.Fprintf(w, "%s%s\n", l.stamp(), msg)
+		return
+	}
+	fmt.Fprintf(w, "%s%s %s\n", l.stamp(), prefix, msg)
+}
+
+// loggerOption adjusts a logger built by newLogger beyond what the
+// settings in Config say.
+type loggerOption func(*consoleLogger)
+
+// withLogFile records every message in f as well, see --log-file. A nil
+// f records nothing.
+func withLogFile(f *os.File) loggerOption {
+	return func(l *consoleLogger) {
+		if f != nil {
+			l.file = f
+		}
+	}
+}
+
+// withQuietLevel makes --quiet mean level rather than errors only.
+func withQuietLevel(level logLevel) loggerOption {
+	return func(l *consoleLogger) {
+		if l.level == levelQuiet {
+			l.level = level
+		}
+	}
+}
+
+// newLogger builds a logger for the logging related settings of cfg.
+func newLogger(cfg Config, opts ...loggerOption) consoleLogger {
+	l := consoleLogger{
+		level:       levelNormal,
+		steps:       &stepTimer{},
+		results:     cfg.JSON,
+		json:        cfg.LogFormat == logFormatJSON || cfg.LogFormat == "" && cfg.JSON,
+		out:         os.Stdout,
+		styles:      logStyles(cfg.ASCII, cfg.LogPrefixes),
+		ascii:       cfg.ASCII,
+		timestamps:  cfg.Timestamps,
+		started:     now(),
+		colorReset:  "\033[0m",
+		colorRed:    "\033[31m",
+		colorGreen:  "\033[32m",
+		colorYellow: "\033[33m",
+		colorBlue:   "\033[34m",
+		colorCyan:   "\033[36m",
+	}
+	// Keep stdout clean for documents, scripts and exports
+	if cfg.JSON || l.json || cfg.EmitScript || cfg.Ephemeral || cfg.EphemeralClean {
+		l.out = os.Stderr
+	}
+	switch {
+	case cfg.Quiet:
+		l.level = levelQuiet
+	case cfg.Verbose:
+		l.level = levelVerbose
+	}
+	l.colorOut = colorEnabled(cfg.Color, l.out)
+	l.colorErr = colorEnabled(cfg.Color, os.Stderr)
+	for _, opt := range opts {
+		opt(&l)
+	}
+	return l
+}
+
+// isTerminal reports whether w is an interactive terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressBar returns a ProgressFunc that redraws a single line bar on the
+// log output. Nothing is drawn when the output isn't a terminal. JSON logs
+// get progress events instead.
+func (l consoleLogger) progressBar() ProgressFunc {
+	if l.level < levelNormal || deterministic {
+		return nil
+	}
+	if l.json {
+		return l.progressEvents()
+	}
+	if !isTerminal(l.out) {
+		return nil
+	}
+
+	const width = 30
+	var last time.Time
+	return func(stage string, current, total int64) {
+		done := total > 0 && current >= total
+		if !done && time.Since(last) < 100*time.Millisecond {
+			return
+		}
+		last = time.Now()
+		// The bar has the line; the spinner waits until it goes quiet
+		l.steps.mu.Lock()
+		defer l.steps.mu.Unlock()
+		l.steps.clearLine()
+		l.steps.drawn = last
+
+		if total <= 0 {
+			fmt.Fprintf(l.out, "\r   %-8s %s", stage, formatBytes(current))
+		} else {
+			// Content-Length can understate the body
+			filled := int(min(max(current*width/total, 0), width))
+			bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+			fmt.Fprintf(l.out, "\r   %-8s [%s] %3d%% %s/%s", stage, bar, current*100/total, formatBytes(current), formatBytes(total))
+		}
+		if done {
+			fmt.Fprintln(l.out)
+		}
+	}
+}
+
+// progressEvents returns a ProgressFunc that logs "progress" events with
+// the byte counts, at most one a second per stage and always the last.
+func (l consoleLogger) progressEvents() ProgressFunc {
+	var last time.Time
+	var lastStage string
+	var lastBytes int64
+	return func(stage string, current, total int64) {
+		done := total > 0 && current >= total
+		if stage == lastStage && (current == lastBytes || !done && time.Since(last) < time.Second) {
+			return
+		}
+		last, lastStage, lastBytes = time.Now(), stage, current
+		ev := logEvent{Level: "progress", Message: stage, Bytes: &current}
+		if total > 0 {
+			ev.Total = &total
+		}
+		l.emit(l.out, ev)
+	}
+}
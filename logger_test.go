@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bufferLogger returns the console logger the flags in args configure,
+// writing to a buffer instead of stdout.
+func bufferLogger(t *testing.T, args ...string) (consoleLogger, *bytes.Buffer) {
+	t.Helper()
+	var cfg Config
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs, &cfg)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	l := newLogger(cfg)
+	l.out = &buf
+	l.colorOut = colorEnabled(cfg.Color, &buf)
+	return l, &buf
+}
+
+func TestColorModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		noColor string
+		color   bool
+	}{
+		{"auto off a terminal", nil, "", false},
+		{"always", []string{"-color", "always"}, "", true},
+		{"always beats NO_COLOR", []string{"-color", "always"}, "1", true},
+		{"never", []string{"-color", "never"}, "", false},
+		{"no-color", []string{"-color", "always", "-no-color"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			l, buf := bufferLogger(t, append([]string{"-ascii"}, tt.args...)...)
+			l.Info("resolved %s", "0.13.0")
+			want := "[info] resolved 0.13.0\n"
+			if tt.color {
+				want = "\033[34m[info]\033[0m resolved 0.13.0\n"
+			}
+			if got := buf.String(); got != want {
+				t.Errorf("logged %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestColorEnabledRespectsNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled(colorAuto, &bytes.Buffer{}) {
+		t.Error("auto colors with NO_COLOR set")
+	}
+	if !colorEnabled(colorAlways, &bytes.Buffer{}) {
+		t.Error("always does not color")
+	}
+	if _, err := parseColorMode("sometimes"); err == nil {
+		t.Error("parseColorMode accepted sometimes")
+	}
+}
+
+func TestLogPrefixStyles(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-ascii=false"}, "💡 info: resolved\n"},
+		{[]string{"-ascii"}, "[info] resolved\n"},
+		{[]string{"-log-prefixes", "info=I"}, "I resolved\n"},
+		{[]string{"-log-prefixes", "info="}, "resolved\n"},
+	}
+	for _, tt := range tests {
+		l, buf := bufferLogger(t, append([]string{"-color", "never"}, tt.args...)...)
+		l.Info("resolved")
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: logged %q, want %q", strings.Join(tt.args, " "), got, tt.want)
+		}
+	}
+}
+
+func TestJSONLogEvents(t *testing.T) {
+	saved := deterministic
+	deterministic = true
+	t.Cleanup(func() { deterministic = saved })
+	t.Setenv("SOURCE_DATE_EPOCH", "1717761600")
+
+	l, buf := bufferLogger(t, "-log-format", "json", "-verbose", "-ascii", "-timestamps=relative")
+	stderr := captureOutput(t, &os.Stderr, func() {
+		l.Info("resolved stable to %s", "0.13.0")
+		l.Step("downloading %s...", "zig-linux-x86_64-0.13.0.tar.xz")
+		progress := l.progressEvents()
+		progress("download", 0, 2048)
+		progress("download", 2048, 2048)
+		progress("extract", 512, 0)
+		l.Warning("index served over plain HTTP")
+		l.Debug("running tar")
+		l.Error("checksum mismatch")
+		l.Success("Zig %s installed successfully 🎉", "0.13.0")
+	})
+	checkGolden(t, filepath.Join("log", "events.ndjson"), buf.Bytes())
+	checkGolden(t, filepath.Join("log", "errors.ndjson"), stderr)
+	if strings.Contains(buf.String()+string(stderr), "\033[") {
+		t.Error("JSON events carry color codes")
+	}
+}
+
+func TestLoggerOptions(t *testing.T) {
+	quiet := newLogger(Config{Quiet: true}, withQuietLevel(levelSilent))
+	if quiet.level != levelSilent {
+		t.Errorf("--quiet with a quiet level of silent gave level %v", quiet.level)
+	}
+	verbose := newLogger(Config{Verbose: true}, withQuietLevel(levelSilent))
+	if verbose.level != levelVerbose {
+		t.Errorf("the quiet level changed the level without --quiet to %v", verbose.level)
+	}
+	if l := newLogger(Config{}, withLogFile(nil)); l.file != nil {
+		t.Error("a nil log file is recorded to")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// silentLogger is a Logger that drops everything, for embedding the
+// installer where its messages are of no interest.
+type silentLogger struct{}
+
+func (silentLogger) Info(string, ...interface{})    {}
+func (silentLogger) Success(string, ...interface{}) {}
+func (silentLogger) Warning(string, ...interface{}) {}
+func (silentLogger) Error(string, ...interface{})   {}
+func (silentLogger) Step(string, ...interface{})    {}
+func (silentLogger) Debug(string, ...interface{})   {}
+func (silentLogger) Progress() ProgressFunc         { return nil }
+
+// logEntry is a message kept by a recordingLogger. Level is one of info,
+// success, warning, error, step, debug and progress; progress entries
+// carry the stage as the message.
+type logEntry struct {
+	Level   string
+	Message string
+}
+
+// recordingLogger is a Logger that keeps every message, so tests and
+// programs embedding the installer can inspect what an install reported.
+// The zero value is ready to use; pass a pointer in Config.Logger.
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+	// progress also records progress reports when set; downloads report
+	// many of them.
+	progress bool
+}
+
+func (r *recordingLogger) add(level, format string, a ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, logEntry{Level: level, Message: fmt.Sprintf(format, a...)})
+}
+
+func (r *recordingLogger) Info(format string, a ...interface{})    { r.add("info", format, a...) }
+func (r *recordingLogger) Success(format string, a ...interface{}) { r.add("success", format, a...) }
+func (r *recordingLogger) Warning(format string, a ...interface{}) { r.add("warning", format, a...) }
+func (r *recordingLogger) Error(format string, a ...interface{})   { r.add("error", format, a...) }
+func (r *recordingLogger) Step(format string, a ...interface{})    { r.add("step", format, a...) }
+func (r *recordingLogger) Debug(format string, a ...interface{})   { r.add("debug", format, a...) }
+
+func (r *recordingLogger) Progress() ProgressFunc {
+	if !r.progress {
+		return nil
+	}
+	return func(stage string, current, total int64) {
+		r.add("progress", "%s %d/%d", stage, current, total)
+	}
+}
+
+// Entries returns the messages recorded so far.
+func (r *recordingLogger) Entries() []logEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]logEntry(nil), r.entries...)
+}
+
+// Messages returns the recorded messages of level.
+func (r *recordingLogger) Messages(level string) []string {
+	var messages []string
+	for _, e := range r.Entries() {
+		if e.Level == level {
+			messages = append(messages, e.Message)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testIndex = `{
+	"master": {
+		"version": "0.14.0-dev.2345+abcdef012",
+		"date": "2024-10-01",
+		"x86_64-linux": {"tarball": "zig-linux-x86_64-0.14.0-dev.2345+abcdef012.tar.xz", "shasum": "0000000000000000000000000000000000000000000000000000000000000000", "size": "1"}
+	},
+	"0.13.0": {
+		"date": "2024-06-07",
+		"x86_64-linux": {"tarball": "zig-linux-x86_64-0.13.0.tar.xz", "shasum": "1111111111111111111111111111111111111111111111111111111111111111", "size": "1"}
+	},
+	"0.12.0": {
+		"date": "2024-04-20",
+		"x86_64-linux": {"tarball": "zig-linux-x86_64-0.12.0.tar.xz", "shasum": "2222222222222222222222222222222222222222222222222222222222222222", "size": "1"}
+	}
+}`
+
+// consoleOutput runs fn and returns what it wrote to stdout and stderr,
+// so tests can check nothing bypassed Config.Logger.
+func consoleOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	var stdout []byte
+	stderr := captureOutput(t, &os.Stderr, func() {
+		stdout = captureOutput(t, &os.Stdout, fn)
+	})
+	return string(stdout) + string(stderr)
+}
+
+// serveIndex serves testIndex over plain HTTP.
+func serveIndex(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testIndex))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestResolveIndexVersionReportsToConfigLogger(t *testing.T) {
+	srv := serveIndex(t)
+	rec := &recordingLogger{}
+	cfg := Config{Version: "stable", IndexURL: srv.URL + "/index.json", CacheDir: t.TempDir(), Logger: rec}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res resolvedVersion
+	console := consoleOutput(t, func() { res, err = resolveIndexVersion(cfg, client) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.version != "0.13.0" {
+		t.Errorf("resolved stable to %s, want 0.13.0", res.version)
+	}
+	if got := rec.Messages("info"); !containsMessage(got, "resolved stable to 0.13.0") {
+		t.Errorf("info messages %q lack the resolved version", got)
+	}
+	if got := rec.Messages("warning"); !containsMessage(got, "plain HTTP") {
+		t.Errorf("warning messages %q lack the plain HTTP warning of the transport", got)
+	}
+	if got := rec.Messages("debug"); !containsMessage(got, "GET "+srv.URL+"/index.json: 200 OK") {
+		t.Errorf("debug messages %q lack the request", got)
+	}
+	if console != "" {
+		t.Errorf("the console was written to:\n%s", console)
+	}
+}
+
+func TestResolveIndexVersionReportsConcreteMaster(t *testing.T) {
+	srv := serveIndex(t)
+	rec := &recordingLogger{}
+	cfg := Config{Version: "master", IndexURL: srv.URL + "/index.json", CacheDir: t.TempDir(), Logger: rec}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := resolveIndexVersion(cfg, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.key != "master" || res.version != "0.14.0-dev.2345+abcdef012" {
+		t.Errorf("resolved master to key %s, version %s", res.key, res.version)
+	}
+	if got := rec.Messages("info"); !containsMessage(got, "master is currently 0.14.0-dev.2345+abcdef012") {
+		t.Errorf("info messages %q lack the concrete version", got)
+	}
+}
+
+func TestSilentLoggerKeepsInstallFlowQuiet(t *testing.T) {
+	srv := serveIndex(t)
+	cfg := Config{Version: "0.12.0", IndexURL: srv.URL + "/index.json", CacheDir: t.TempDir(), Logger: silentLogger{}}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	console := consoleOutput(t, func() { _, err = resolveIndexVersion(cfg, client) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if console != "" {
+		t.Errorf("the console was written to:\n%s", console)
+	}
+}
+
+func TestExtractTarballReportsToGivenLogger(t *testing.T) {
+	dir := t.TempDir()
+	src := writeTarGz(t, dir, map[string]string{
+		"zig-linux-x86_64-0.13.0/zig":             "binary",
+		"zig-linux-x86_64-0.13.0/lib/std/std.zig": "",
+	})
+	rec := &recordingLogger{}
+
+	var err error
+	console := consoleOutput(t, func() { err = extractTarball(rec, src, filepath.Join(dir, "out"), -1, "auto", nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Messages("debug"); !containsMessage(got, "running tar") {
+		t.Errorf("debug messages %q lack the tar command", got)
+	}
+	if console != "" {
+		t.Errorf("the console was written to:\n%s", console)
+	}
+}
+
+func TestRecordingLoggerProgress(t *testing.T) {
+	var quiet recordingLogger
+	if quiet.Progress() != nil {
+		t.Error("progress is reported without being asked for")
+	}
+	rec := &recordingLogger{progress: true}
+	rec.Progress()("download", 5, 10)
+	if got := rec.Messages("progress"); len(got) != 1 || got[0] != "download 5/10" {
+		t.Errorf("progress entries %q, want [download 5/10]", got)
+	}
+}
+
+// containsMessage reports whether any of messages contains s.
+func containsMessage(messages []string, s string) bool {
+	for _, m := range messages {
+		if strings.Contains(m, s) {
+			return true
+		}
+	}
+	return false
+}
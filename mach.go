@@ -0,0 +1,62 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+const defaultMachIndexURL = "https://machengine.org/zig/index.json"
+
+// machVersionPattern matches Mach nominated versions such as 2024.5.0-mach
+// and the moving "mach-latest" alias.
+var machVersionPattern = regexp.MustCompile(`^(\d{4}\.\d+\.\d+-mach|mach-latest)$`)
+
+func isMachVersion(v string) bool {
+	return machVersionPattern.MatchString(v)
+}
+
+// indexURLFor picks the index to query for the requested version. With
+// the "auto" flavor, Mach style versions go to the Mach index and
+// everything else to the regular one for the version's channel.
+func indexURLFor(cfg Config, version string) (string, error) {
+	if cfg.IndexFile != "" {
+		return indexFileURL(cfg.IndexFile), nil
+	}
+	switch cfg.IndexFlavor {
+	case "ziglang":
+		return channelIndexURL(cfg, version), nil
+	case "mach":
+		return cfg.MachIndexURL, nil
+	case "", "auto":
+		if isMachVersion(version) {
+			return cfg.MachIndexURL, nil
+		}
+		return channelIndexURL(cfg, version), nil
+	}
+	return "", newError(kindUsage, "unknown index flavor %q (expected auto, ziglang or mach)", cfg.IndexFlavor)
+}
+
+// versionChannel returns "nightly" for master and dev builds and "stable"
+// for everything else, including aliases and ranges.
+func versionChannel(version string) string {
+	if version == "master" {
+		return "nightly"
+	}
+	if v, err := parseVersion(version); err == nil && v.isPrerelease() {
+		return "nightly"
+	}
+	return "stable"
+}
+
+// channelIndexURL applies the per-channel overrides, falling back to the
+// regular index URL with any {channel} placeholder filled in.
+func channelIndexURL(cfg Config, version string) string {
+	channel := versionChannel(version)
+	switch {
+	case channel == "nightly" && cfg.NightlyIndexURL != "":
+		return cfg.NightlyIndexURL
+	case channel == "stable" && cfg.StableIndexURL != "":
+		return cfg.StableIndexURL
+	}
+	return strings.ReplaceAll(cfg.IndexURL, "{channel}", channel)
+}
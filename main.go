@@ -1,92 +1,358 @@
 package main
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path"
 	"path/filepath"
-	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 type Config struct {
-	TarDest   string
-	Dest      string
-	BinDir    string
-	LibDir    string
-	IndexURL  string
+	TarDest  string
+	Dest     string
+	BinDir   string
+	LibDir   string
+	BinName  string
+	Root     string
+	IndexURL string
+	// IndexFile is a local index used instead of any index URL.
+	IndexFile string
 	Version   string
+	PinCert   string
+	PinHost   string
+	// MinTLSVersion is the oldest TLS version connections may use, such
+	// as "1.2".
+	MinTLSVersion string
+
+	// ReportURL receives a JSON report after each install (opt-in).
+	ReportURL string
+
+	UserAgent string
+
+	// ExpectedSHA256 is a user pinned checksum that must agree with the
+	// index and the downloaded file.
+	ExpectedSHA256 string
+	// ChecksumURL is a GNU or BSD style checksum list the tarball must
+	// be listed in with the same SHA-256.
+	ChecksumURL string
+	Mirrors     string
+	// GitHubFallback is the repository whose releases serve stable
+	// tarballs that could not be downloaded otherwise.
+	GitHubFallback string
+
+	JSON          bool
+	Verbose       bool
+	Quiet         bool
+	Deterministic bool
+	ASCII         bool
+	Color         string
+	LogFormat     string
+	LogFile       string
+	Timestamps    string
+	LogPrefixes   map[string]string
+	Since         bool
+	Force         bool
+	DryRun        bool
+	EmitScript    bool
+	Yes           bool
+	KeepGoing     bool
+	Prerelease    prereleasePolicy
+
+	// Ephemeral installs into a throwaway prefix, and EphemeralClean
+	// removes it again; both print environment for eval.
+	Ephemeral      bool
+	EphemeralClean bool
+
+	NoDownloadIfPresent bool
+	MaxDownloadSize     int64
+	InstallTarballTo    string
+	Prefetch            []string
+	WriteLock           string
+	Provenance          string
+	BackupDir           string
+	KeepOld             bool
+	RequireHTTPS        bool
+	UpdatePath          bool
+	FromLock            string
+	Clean               bool
+	AllowForeignHost    bool
+	VerifyTarball       string
+	Which               bool
+	PrintPlatform       bool
+	Platforms           bool
+	ListInstalled       bool
+	Target              string
+	StripComponents     int
+	Decompressor        string
+	PreferHostArch      bool
+	OS                  string
+	Arch                string
+	CPUVariant          string
+	DestOnly            bool
+
+	// Progress receives download and extraction progress. The CLI
+	// renders it as a progress bar; library users can plug in their own.
+	Progress ProgressFunc
+	// Logger receives the messages of the install flow. The CLI sets it
+	// to the console logger configured by the flags; nil is a console
+	// logger for the other settings, see Config.log.
+	Logger Logger
+
+	FileMode os.FileMode
+	DirMode  os.FileMode
+
+	IndexFlavor     string
+	IndexFormat     string
+	DevURLTemplate  string
+	MachIndexURL    string
+	StableIndexURL  string
+	NightlyIndexURL string
+
+	Serve    string
+	CacheDir string
+	CacheTTL time.Duration
+
+	WaitForNetwork time.Duration
+	IndexTimeout   time.Duration
+
+	Completion         string
+	InstallCompletions bool
+	InstallMan         bool
 }
 
-type Logger struct {
-	colorReset  string
-	colorRed    string
-	colorGreen  string
-	colorYellow string
-	colorBlue   string
-	colorCyan   string
-}
-
-func (l Logger) info(format string, a ...interface{}) {
-	fmt.Printf("💡 "+l.colorBlue+"info:"+l.colorReset+" %s\n", fmt.Sprintf(format, a...))
-}
-
-func (l Logger) success(format string, a ...interface{}) {
-	fmt.Printf("✅ "+l.colorGreen+"success:"+l.colorReset+" %s\n", fmt.Sprintf(format, a...))
-}
-
-func (l Logger) warning(format string, a ...interface{}) {
-	fmt.Printf("⚠️  "+l.colorYellow+"warning:"+l.colorReset+" %s\n", fmt.Sprintf(format, a...))
-}
+// buildVersion is set at build time via -ldflags "-X main.buildVersion=...".
+var buildVersion = ""
 
-func (l Logger) error(format string, a ...interface{}) {
-	fmt.Fprintf(os.Stderr, "❌ "+l.colorRed+"error:"+l.colorReset+" %s\n", fmt.Sprintf(format, a...))
-}
-
-func (l Logger) step(format string, a ...interface{}) {
-	fmt.Printf("👉 "+l.colorCyan+"step:"+l.colorReset+" %s\n", fmt.Sprintf(format, a...))
+// installerVersion reports the version of zig-installer itself.
+func installerVersion() string {
+	if buildVersion != "" {
+		return buildVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
 }
 
-var logger = Logger{
-	colorReset:  "\033[0m",
-	colorRed:    "\033[31m",
-	colorGreen:  "\033[32m",
-	colorYellow: "\033[33m",
-	colorBlue:   "\033[34m",
-	colorCyan:   "\033[36m",
+// registerFlags defines the flags shared by the install flow and every
+// subcommand on fs.
+func registerFlags(fs *flag.FlagSet, cfg *Config) {
+	prefix, tmp := defaultDirs()
+	fs.StringVar(&cfg.TarDest, "tar-dest", getEnv("ZIG_TAR_DEST", filepath.Join(tmp, "zig.tar.xz")), "Path to download the Zig tarball")
+	fs.StringVar(&cfg.Dest, "dest", getEnv("ZIG_DEST", filepath.Join(tmp, "zig")), "Temporary directory for extraction")
+	fs.StringVar(&cfg.BinDir, "bin-dir", getEnv("ZIG_BIN_DIR", filepath.Join(prefix, "bin")), "Installation directory for Zig binary")
+	fs.StringVar(&cfg.LibDir, "lib-dir", getEnv("ZIG_LIB_DIR", filepath.Join(prefix, "lib")), "Installation directory for Zig libraries")
+	fs.StringVar(&cfg.BinName, "bin-name", getEnv("ZIG_BIN_NAME", "zig"), "Name of the installed binary; other names get their own lib directory (e.g., zig-nightly)")
+	fs.BoolFunc("update-nightly", "Install master as zig-nightly next to the regular zig, skipping it if master has not moved", func(string) error {
+		cfg.Version, cfg.BinName, cfg.Since = "master", "zig-nightly", true
+		return nil
+	})
+	fs.Func("prefix", "Set --bin-dir and --lib-dir to <prefix>/bin and <prefix>/lib", func(prefix string) error {
+		cfg.BinDir = filepath.Join(prefix, "bin")
+		cfg.LibDir = filepath.Join(prefix, "lib")
+		return nil
+	})
+	fs.StringVar(&cfg.Target, "target", getEnv("ZIG_TARGET", ""), "Platform key to install for instead of the host's (e.g., aarch64-linux)")
+	fs.BoolVar(&cfg.PreferHostArch, "prefer-host-arch", false, "Under Rosetta, install the x86_64 build matching the installer instead of the native aarch64 one")
+	fs.StringVar(&cfg.OS, "os", "", "OS half of the platform key to install for, keeping the host's architecture (e.g., linux)")
+	fs.StringVar(&cfg.Arch, "arch", "", "Architecture half of the platform key to install for, keeping the host's OS (e.g., aarch64)")
+	fs.StringVar(&cfg.CPUVariant, "cpu-variant", "", "CPU variant to install where the index lists several builds of a platform (default: the baseline build)")
+	fs.StringVar(&cfg.Decompressor, "decompressor", "auto", "Program to decompress the tarball with ahead of tar: auto (pixz or pigz when installed), tar, xz, pixz or pigz")
+	fs.IntVar(&cfg.StripComponents, "strip-components", -1, "Leading directories to drop from tarball entries; -1 detects them from the archive")
+	fs.BoolVar(&cfg.DestOnly, "dest-only", false, "Download, verify and extract into --dest without installing into --bin-dir/--lib-dir")
+	fs.StringVar(&cfg.Root, "root", getEnv("ZIG_ROOT", ""), "Install below this staging root (e.g., an image rootfs) instead of /")
+	fs.StringVar(&cfg.IndexFile, "index-file", getEnv("ZIG_INDEX_FILE", ""), "Read the index from this local file instead of fetching it (checked like a fetched one)")
+	fs.StringVar(&cfg.IndexURL, "index-url", getEnv("ZIG_INDEX_URL", "https://ziglang.org/download/index.json"), "URL for Zig download index, or a comma-separated list to merge ({channel} is replaced by stable or nightly)")
+	fs.StringVar(&cfg.StableIndexURL, "stable-index-url", getEnv("ZIG_STABLE_INDEX_URL", ""), "Index URL for tagged releases (overrides --index-url)")
+	fs.StringVar(&cfg.NightlyIndexURL, "nightly-index-url", getEnv("ZIG_NIGHTLY_INDEX_URL", ""), "Index URL for master and dev builds (overrides --index-url)")
+	fs.StringVar(&cfg.IndexFlavor, "index-flavor", getEnv("ZIG_INDEX_FLAVOR", "auto"), "Index to query: auto, ziglang or mach")
+	fs.StringVar(&cfg.IndexFormat, "index-format", getEnv("ZIG_INDEX_FORMAT", "ziglang"), "Layout of the index: ziglang or custom")
+	fs.StringVar(&cfg.DevURLTemplate, "dev-url-template", getEnv("ZIG_DEV_URL_TEMPLATE", defaultDevURLTemplate), "URL for dev builds missing from the index ({os}, {arch} and {version} are filled in)")
+	fs.StringVar(&cfg.MachIndexURL, "mach-index-url", getEnv("ZIG_MACH_INDEX_URL", defaultMachIndexURL), "URL for the Mach nominated versions index")
+	fs.StringVar(&cfg.Version, "version", getEnv("ZIG_VERSION", "master"), "Zig version to install (e.g., master, stable, 0.11.0)")
+	cfg.FileMode, cfg.DirMode = 0755, 0755
+	fs.Var(modeFlag{&cfg.FileMode}, "file-mode", "Permissions for the installed binary (lib files get the same without execute bits)")
+	fs.Var(modeFlag{&cfg.DirMode}, "dir-mode", "Permissions for created and installed directories")
+	fs.StringVar(&cfg.VerifyTarball, "verify-tarball", "", "Verify the given tarball against the index for --version and exit (5 if it does not match)")
+	fs.BoolVar(&cfg.ListInstalled, "list-installed", false, "List the versions installed below --lib-dir and exit")
+	fs.BoolVar(&cfg.Which, "which", false, "Show where the managed zig lives, what it links to and its version, and exit")
+	fs.BoolVar(&cfg.Platforms, "platforms", false, "List the platform keys the index ships --version for, marking the one this host computes, and exit")
+	fs.BoolVar(&cfg.PrintPlatform, "print-platform", false, "Show how this host maps to a platform key and whether the index has a build of --version for it, and exit")
+	fs.BoolVar(&cfg.Verbose, "verbose", false, "Show debug output: HTTP requests, tar commands, resolved paths and how long each step took")
+	fs.BoolVar(&cfg.Verbose, "v", false, "Shorthand for --verbose")
+	fs.BoolVar(&cfg.Quiet, "quiet", false, "Only print errors, for cron jobs (check-update prints nothing); overrides --verbose")
+	fs.BoolVar(&cfg.Quiet, "q", false, "Shorthand for --quiet")
+	fs.BoolVar(&cfg.Deterministic, "deterministic", getEnv("ZIG_DETERMINISTIC", "") != "", "Disable progress output and pin timestamps (to SOURCE_DATE_EPOCH or 0) for reproducible output")
+	fs.BoolVar(&cfg.ASCII, "ascii", getEnv("ZIG_ASCII", "") != "" || !unicodeTerminal(), "Prefix log lines with plain [info], [ok], [warn], [error] and [step] tags instead of emoji (default on when the locale is not UTF-8 or TERM is dumb)")
+	cfg.Color = colorAuto
+	fs.Func("color", "Color log prefixes: auto (terminals only, off when NO_COLOR is set), always or never (default auto)", func(s string) error {
+		mode, err := parseColorMode(s)
+		cfg.Color = mode
+		return err
+	})
+	fs.BoolFunc("no-color", "Disable colored output (same as --color=never)", func(string) error {
+		cfg.Color = colorNever
+		return nil
+	})
+	fs.Func("log-format", "Log as text or as one JSON object per line on stderr (default text, json with --json)", func(s string) error {
+		format, err := parseLogFormat(s)
+		cfg.LogFormat = format
+		return err
+	})
+	cfg.Timestamps, _ = parseTimestamps(getEnv("ZIG_TIMESTAMPS", ""))
+	fs.Var(timestampsFlag{&cfg.Timestamps}, "timestamps", "Prefix log lines with the time (rfc3339, the default when given without a value) or the time since the start (relative, e.g. +12.3s)")
+	fs.StringVar(&cfg.LogFile, "log-file", getEnv("ZIG_LOG_FILE", ""), "Also append all log output, debug lines included, to this file (secrets redacted)")
+	fs.Func("log-prefixes", "Replace the prefix of log levels, as comma-separated level=prefix pairs (e.g., info=INFO,error=ERROR)", func(s string) error {
+		prefixes, err := parseLogPrefixes(s)
+		for level, prefix := range prefixes {
+			if cfg.LogPrefixes == nil {
+				cfg.LogPrefixes = make(map[string]string)
+			}
+			cfg.LogPrefixes[level] = prefix
+		}
+		return err
+	})
+	fs.BoolVar(&cfg.JSON, "json", false, "Print results as JSON on stdout and send log output to stderr")
+	fs.BoolVar(&cfg.Yes, "yes", false, "Download and install without asking for confirmation on interactive runs")
+	fs.BoolVar(&cfg.Yes, "y", false, "Shorthand for --yes")
+	fs.BoolVar(&cfg.KeepGoing, "keep-going", false, "In batches (--prefetch, upgrade --all), attempt every item after one fails and report all failures at the end")
+	fs.BoolVar(&cfg.KeepGoing, "k", false, "Shorthand for --keep-going")
+	fs.BoolVar(&cfg.Force, "force", false, "Reinstall even if the same version is already installed")
+	fs.BoolVar(&cfg.EmitScript, "emit-script", false, "Print a POSIX shell script that downloads, verifies and installs the resolved release, instead of installing")
+	fs.BoolVar(&cfg.Ephemeral, "ephemeral", false, "Install into a new temporary prefix and print the PATH and ZIG_LIB_DIR exports to eval for this shell session")
+	fs.BoolVar(&cfg.EphemeralClean, "ephemeral-clean", false, "Remove the prefix of --ephemeral named by ZIG_EPHEMERAL and print the exports that undo it")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "Resolve the release and print what the install would download, remove and write, without changing anything")
+	cfg.MaxDownloadSize = defaultMaxDownloadSize
+	fs.Var(sizeFlag{&cfg.MaxDownloadSize}, "max-download-size", "Refuse downloads larger than this (e.g., 500MB; 0 for no limit)")
+	fs.BoolVar(&cfg.NoDownloadIfPresent, "no-download-if-present", false, "Use an existing --tar-dest file as-is without downloading or verifying it")
+	fs.StringVar(&cfg.InstallTarballTo, "install-tarball-to", "", "Copy the verified tarball into this directory instead of installing it")
+	fs.Func("prefetch", "Download and verify the tarballs for these comma-separated platforms into --cache-dir instead of installing (repeatable)", func(s string) error {
+		cfg.Prefetch = append(cfg.Prefetch, splitList(s)...)
+		return nil
+	})
+	fs.BoolVar(&cfg.Clean, "clean", true, "Remove the tarball and extraction directory before and after installing")
+	fs.BoolFunc("no-clean", "Keep the tarball and extraction directory (same as --clean=false)", func(string) error {
+		cfg.Clean = false
+		return nil
+	})
+	fs.BoolVar(&cfg.KeepOld, "keep-old", false, "Rename the previous install to zig.old in --bin-dir and --lib-dir instead of deleting it")
+	fs.BoolVar(&cfg.UpdatePath, "update-path", false, "Add --bin-dir to PATH in your shell profile (the user Path on Windows) if it is missing")
+	fs.StringVar(&cfg.BackupDir, "backup-dir", getEnv("ZIG_BACKUP_DIR", ""), "Move the previous install into a timestamped directory here instead of deleting it")
+	fs.StringVar(&cfg.WriteLock, "write-lock", "", "Write the resolved release to this lockfile (e.g., "+defaultLockName+")")
+	fs.StringVar(&cfg.Provenance, "provenance", "", "Also write the provenance record of the install to this file (verify reads it back)")
+	fs.StringVar(&cfg.FromLock, "from-lock", "", "Install exactly the release recorded in this lockfile, skipping the index")
+	fs.BoolFunc("allow-prerelease", "Let latest and version ranges resolve to dev builds", prereleaseFlag(&cfg.Prerelease, prereleaseAllow))
+	fs.BoolFunc("only-prerelease", "Only let latest and version ranges resolve to dev builds", prereleaseFlag(&cfg.Prerelease, prereleaseOnly))
+	fs.BoolVar(&cfg.Since, "since", false, "Skip the install unless the index has a newer version than the one installed (useful for master)")
+	fs.BoolVar(&cfg.AllowForeignHost, "allow-foreign-host", false, "Accept tarball URLs in the index that point at another host than the index or a mirror")
+	fs.StringVar(&cfg.Mirrors, "mirror", getEnv("ZIG_MIRROR", ""), "Comma-separated mirror base URLs to try before the upstream tarball URL")
+	fs.StringVar(&cfg.GitHubFallback, "github-fallback", getEnv("ZIG_GITHUB_FALLBACK", ""), "GitHub repository (owner/name) whose release of the version serves the tarball when mirrors and upstream fail, checked against its SHASUMS256.txt asset")
+	fs.StringVar(&cfg.Serve, "serve", "", "Run a caching index/tarball server on the given address (e.g., :8080)")
+	fs.StringVar(&cfg.CacheDir, "cache-dir", getEnv("ZIG_CACHE_DIR", defaultCacheDir()), "Directory for cached downloads")
+	fs.DurationVar(&cfg.IndexTimeout, "index-timeout", defaultIndexTimeout, "Give up on an index request that takes longer than this (0 for no limit); tarball downloads are not affected")
+	fs.DurationVar(&cfg.WaitForNetwork, "wait-for-network", 0, "Poll the index until it is reachable for up to this long before installing (e.g., 2m)")
+	fs.DurationVar(&cfg.CacheTTL, "cache-ttl", 0, "Reuse a cached index younger than this without fetching (e.g., 1h)")
+	fs.StringVar(&cfg.Completion, "completion", "", "Print the completion script for the given shell (bash, zsh, fish) and exit")
+	fs.BoolVar(&cfg.InstallCompletions, "install-completions", false, "Install the completion script for the current shell after installing")
+	fs.BoolVar(&cfg.InstallMan, "install-man", false, "Install a generated man page alongside the completions")
+	fs.StringVar(&cfg.ReportURL, "report-url", getEnv("ZIG_REPORT_URL", ""), "POST a JSON report of each install (version, platform, success, duration, checksum) to this URL")
+	fs.StringVar(&cfg.UserAgent, "user-agent", getEnv("ZIG_USER_AGENT", ""), "User-Agent header for all requests (default \"zig-installer/<version>\")")
+	fs.BoolVar(&cfg.RequireHTTPS, "require-https", false, "Refuse to fetch anything over plain HTTP, including redirects")
+	fs.StringVar(&cfg.PinHost, "pin-host", getEnv("ZIG_PIN_HOST", ""), "Comma-separated hosts redirects may lead to (subdomains included); other redirects fail")
+	fs.StringVar(&cfg.PinCert, "pin-cert", getEnv("ZIG_PIN_CERT", ""), "SHA-256 fingerprint the server's leaf TLS certificate must match")
+	fs.StringVar(&cfg.MinTLSVersion, "min-tls-version", getEnv("ZIG_MIN_TLS_VERSION", "1.2"), "Oldest TLS version the index and download connections may use: 1.0, 1.1, 1.2 or 1.3")
+	fs.StringVar(&cfg.ChecksumURL, "checksum-url", getEnv("ZIG_CHECKSUM_URL", ""), "Checksum list (GNU or BSD format) that must list the tarball with the same SHA-256")
+	fs.StringVar(&cfg.ExpectedSHA256, "expected-sha256", getEnv("ZIG_EXPECTED_SHA256", ""), "SHA-256 the tarball must have; it must also agree with the index")
 }
 
-func getConfig() Config {
+// getConfig parses the flags of the default install flow. The logger
+// they configure goes to cfg.Logger and *log.
+func getConfig(log *Logger) (Config, error) {
 	var cfg Config
-
-	flag.StringVar(&cfg.TarDest, "tar-dest", getEnv("ZIG_TAR_DEST", "/tmp/zig.tar.xz"), "Path to download the Zig tarball")
-	flag.StringVar(&cfg.Dest, "dest", getEnv("ZIG_DEST", "/tmp/zig"), "Temporary directory for extraction")
-	flag.StringVar(&cfg.BinDir, "bin-dir", getEnv("ZIG_BIN_DIR", "/usr/local/bin"), "Installation directory for Zig binary")
-	flag.StringVar(&cfg.LibDir, "lib-dir", getEnv("ZIG_LIB_DIR", "/usr/local/lib"), "Installation directory for Zig libraries")
-	flag.StringVar(&cfg.IndexURL, "index-url", getEnv("ZIG_INDEX_URL", "https://ziglang.org/download/index.json"), "URL for Zig download index")
-	flag.StringVar(&cfg.Version, "version", getEnv("ZIG_VERSION", "master"), "Zig version to install (e.g., master, 0.11.0)")
+	registerFlags(flag.CommandLine, &cfg)
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s [flags]            install Zig\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s <command> [flags]  run a command\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nCommands:\n")
+		fmt.Fprintf(os.Stderr, "  cache list      List cached indexes and tarballs\n")
+		fmt.Fprintf(os.Stderr, "  check-update    Exit 10 if a newer version than the installed one exists\n")
+		fmt.Fprintf(os.Stderr, "  diff <a> <b>    Compare the metadata of two versions\n")
+		fmt.Fprintf(os.Stderr, "  info <version>  Show release metadata\n")
+		fmt.Fprintf(os.Stderr, "  install         Install Zig (same as no command)\n")
+		fmt.Fprintf(os.Stderr, "  list            List versions available in the index\n")
+		fmt.Fprintf(os.Stderr, "  notes <version> Show the release notes link (--fetch to read them)\n")
+		fmt.Fprintf(os.Stderr, "  status          Show the active install\n")
+		fmt.Fprintf(os.Stderr, "  outdated        Compare installed versions with the index\n")
+		fmt.Fprintf(os.Stderr, "  upgrade         Reinstall installed versions that are out of date\n")
+		fmt.Fprintf(os.Stderr, "  verify          Check the installed tree against its provenance record\n")
+		fmt.Fprintf(os.Stderr, "  zls <command>   List, show or install zls releases (list, info, install)\n")
 		fmt.Fprintf(os.Stderr, "\nEnvironment variables:\n")
-		fmt.Fprintf(os.Stderr, "  ZIG_TAR_DEST   Path to download the Zig tarball\n")
-		fmt.Fprintf(os.Stderr, "  ZIG_DEST       Temporary directory for extraction\n")
-		fmt.Fprintf(os.Stderr, "  ZIG_BIN_DIR    Installation directory for Zig binary\n")
-		fmt.Fprintf(os.Stderr, "  ZIG_LIB_DIR    Installation directory for Zig libraries\n")
-		fmt.Fprintf(os.Stderr, "  ZIG_INDEX_URL  URL for Zig download index\n")
-		fmt.Fprintf(os.Stderr, "  ZIG_VERSION    Zig version to install (e.g., master, 0.11.0)\n\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_TAR_DEST           Path to download the Zig tarball\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_DEST               Temporary directory for extraction\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_BIN_DIR            Installation directory for Zig binary\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_LIB_DIR            Installation directory for Zig libraries\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_BIN_NAME           Name of the installed binary\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_TARGET             Platform key to install for\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_ROOT               Staging root to install below\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_INDEX_URL          URL for Zig download index\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_INDEX_FILE         Local index file to use instead of fetching one\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_STABLE_INDEX_URL   Index URL for tagged releases\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_NIGHTLY_INDEX_URL  Index URL for master and dev builds\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_INDEX_FLAVOR       Index to query: auto, ziglang or mach\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_INDEX_FORMAT       Layout of the index: ziglang or custom\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_DEV_URL_TEMPLATE   URL for dev builds missing from the index\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_MACH_INDEX_URL     URL for the Mach nominated versions index\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_VERSION            Zig version to install (e.g., master, stable, 0.11.0)\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_MIRROR             Comma-separated mirror base URLs\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_BACKUP_DIR         Directory to keep previous installs in\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_CACHE_DIR          Directory for cached downloads\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_REPORT_URL         URL to POST install reports to\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_USER_AGENT         User-Agent header for all requests\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_PIN_HOST           Hosts redirects may lead to\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_PIN_CERT           SHA-256 fingerprint the server's leaf TLS certificate must match\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_MIN_TLS_VERSION    Oldest TLS version connections may use\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_DETERMINISTIC      Set to any value to imply --deterministic\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_ASCII              Set to any value to imply --ascii (on by default without a UTF-8 locale)\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_LOG_FILE           File to append all log output to\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_TIMESTAMPS         Log line timestamps: rfc3339 or relative\n")
+		fmt.Fprintf(os.Stderr, "  NO_COLOR               Set to any value to disable colors unless --color=always\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_CHECKSUM_URL       Checksum list the tarball must be listed in\n")
+		fmt.Fprintf(os.Stderr, "  ZIG_EXPECTED_SHA256    SHA-256 the tarball must have\n\n")
 		fmt.Fprintf(os.Stderr, "Flags:\n")
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExit codes:\n")
+		fmt.Fprintf(os.Stderr, "  0    success\n")
+		fmt.Fprintf(os.Stderr, "  1    other error\n")
+		fmt.Fprintf(os.Stderr, "  2    usage error\n")
+		fmt.Fprintf(os.Stderr, "  3    version or platform not found\n")
+		fmt.Fprintf(os.Stderr, "  4    network failure\n")
+		fmt.Fprintf(os.Stderr, "  5    checksum mismatch\n")
+		fmt.Fprintf(os.Stderr, "  6    permission or filesystem error\n")
+		fmt.Fprintf(os.Stderr, "  10   update available (check-update)\n")
+		fmt.Fprintf(os.Stderr, "  130  interrupted\n")
 	}
 
 	flag.Parse()
-	return cfg
+	if err := setupLogger(log, &cfg, flag.CommandLine); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
 }
 
 func getEnv(key, fallback string) string {
@@ -96,19 +362,83 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// prereleaseFlag sets *p to policy when a boolean pre-release flag is
+// given, and back to the default when it is given as false.
+func prereleaseFlag(p *prereleasePolicy, policy prereleasePolicy) func(string) error {
+	return func(s string) error {
+		on, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		if on {
+			*p = policy
+		} else if *p == policy {
+			*p = prereleaseExclude
+		}
+		return nil
+	}
+}
+
+// rooted maps a path on the target system to where it lives on this one,
+// i.e. below --root when set.
+func (cfg Config) rooted(path string) string {
+	if cfg.Root == "" {
+		return path
+	}
+	return filepath.Join(cfg.Root, path)
+}
+
+// log returns the Logger the install flow reports to.
+func (cfg Config) log() Logger {
+	if cfg.Logger != nil {
+		return cfg.Logger
+	}
+	return newLogger(cfg)
+}
+
+// splitList splits a comma separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "zig-installer")
+	}
+	return filepath.Join(dir, "zig-installer")
+}
+
 func checkDependencies() error {
 	deps := []string{"tar"}
 	for _, dep := range deps {
 		_, err := exec.LookPath(dep)
 		if err != nil {
-			return fmt.Errorf("missing dependency: %s", dep)
+			return newError(kindNotFound, "missing dependency: %s", dep)
+		}
+	}
+	// Termux's tar can't unpack .tar.xz until xz is installed
+	if termuxPrefix() != "" {
+		if _, err := exec.LookPath("xz"); err != nil {
+			return newError(kindNotFound, "missing dependency: xz, which tar needs for Zig's .tar.xz releases; install it with: pkg install xz-utils")
 		}
 	}
 	return nil
 }
 
-func downloadFile(url, dest string) error {
-	resp, err := http.Get(url)
+// downloadFile saves url to dest. A positive maxSize caps the download,
+// whatever Content-Length claims.
+func downloadFile(log Logger, client *http.Client, url, dest string, maxSize int64, progress ProgressFunc) error {
+	if src, ok := parseSSHSource(url); ok {
+		return downloadSSH(log, src, dest, maxSize, progress)
+	}
+	resp, err := client.Get(url)
 	if err != nil {
 		return err
 	}
@@ -117,6 +447,9 @@ func downloadFile(url, dest string) error {
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return fmt.Errorf("%s is %s, more than --max-download-size %s", url, formatBytes(resp.ContentLength), formatBytes(maxSize))
+	}
 
 	out, err := os.Create(dest)
 	if err != nil {
@@ -124,8 +457,44 @@ func downloadFile(url, dest string) error {
 	}
 	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
-	return err
+	var body io.Reader = resp.Body
+	if maxSize > 0 {
+		body = io.LimitReader(body, maxSize+1)
+	}
+	// A digest header is checked on the fly, without another pass
+	var w io.Writer = out
+	digest := responseDigest(resp)
+	var h hash.Hash
+	if digest != nil {
+		h = digest.newHash()
+		w = io.MultiWriter(out, h)
+	}
+	n, err := io.Copy(w, newProgressReader(body, "download", resp.ContentLength, progress))
+	if err != nil {
+		return err
+	}
+	if maxSize > 0 && n > maxSize {
+		return fmt.Errorf("%s exceeds --max-download-size %s, aborted", url, formatBytes(maxSize))
+	}
+	if digest != nil {
+		return digest.check(log, url, h)
+	}
+	return nil
+}
+
+// downloadTarball tries every configured mirror before falling back to the
+// upstream URL. Mirrors are expected to serve tarballs under their
+// upstream file name.
+func downloadTarball(log Logger, client *http.Client, mirrors, url, dest string, maxSize int64, progress ProgressFunc) error {
+	for _, mirror := range splitList(mirrors) {
+		mirrorURL := strings.TrimSuffix(mirror, "/") + "/" + path.Base(url)
+		if err := downloadFile(log, client, mirrorURL, dest, maxSize, progress); err != nil {
+			log.Warning("mirror %s failed: %v", mirror, err)
+			continue
+		}
+		return nil
+	}
+	return downloadFile(log, client, url, dest, maxSize, progress)
 }
 
 func verifyChecksum(file, expectedSum string) error {
@@ -142,183 +511,198 @@ func verifyChecksum(file, expectedSum string) error {
 
 	sum := hex.EncodeToString(h.Sum(nil))
 	if sum != expectedSum {
-		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedSum, sum)
+		return &checksumError{expected: expectedSum, got: sum}
 	}
 	return nil
 }
 
-func extractTarball(src, dest string) error {
+// extractTarball unpacks src into dest. The archive is streamed to tar's
+// stdin so progress can be reported in compressed bytes. A negative strip
+// is detected from the archive's entries.
+func extractTarball(log Logger, src, dest string, strip int, decompressor string, progress ProgressFunc) error {
+	if strip >= 0 {
+		return extractArchive(log, src, dest, strip, decompressor, progress)
+	}
+	detected, err := extractHoisted(log, src, dest, decompressor, progress)
+	if err != nil {
+		return err
+	}
+	log.Debug("detected --strip-components=%d from the archive entries", detected)
+	return nil
+}
+
+// extractArchive extracts src into dest, dropping strip leading path
+// components.
+func extractArchive(log Logger, src, dest string, strip int, decompressor string, progress ProgressFunc) error {
 	if err := os.MkdirAll(dest, 0755); err != nil {
 		return err
 	}
+	format, err := sniffArchive(src)
+	if err != nil {
+		return err
+	}
+	if format == formatZip {
+		return extractZip(src, dest, strip, progress)
+	}
 
-	args := []string{"-xf", src, "-C", dest, "--strip-components=1"}
-	if strings.HasSuffix(src, ".tar.xz") {
-		args = append([]string{"-J"}, args...)
+	f, err := os.Open(src)
+	if err != nil {
+		return err
 	}
-	cmd := exec.Command("tar", args...)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("tar extraction failed: %v: %s", err, out)
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	in := newProgressReader(f, "extract", info.Size(), progress)
+	out, stderr, err := runTar(log, in, format, decompressor, "-xf", "-", "-C", dest, fmt.Sprintf("--strip-components=%d", strip))
+	if err != nil {
+		if errorKindOf(err) != kindGeneric {
+			return err
+		}
+		log.Debug("tar output: %s", strings.TrimSpace(string(append(out, stderr...))))
+		return tarError(err, stderr)
 	}
 	return nil
 }
 
-func ensureDirectoryExists(path string) error {
-	return os.MkdirAll(path, 0755)
+// corruptionMessages are fragments of GNU tar, bsdtar and xz errors that
+// mean the archive itself is damaged rather than the disk or permissions.
+var corruptionMessages = []string{
+	"unexpected end of input",
+	"unexpected eof",
+	"truncated input",
+	"compressed data is corrupt",
+	"file format not recognized",
+	"not in xz format",
+	"unrecognized archive format",
+	"does not look like a tar archive",
+	"damaged tar archive",
+	"skipping to next header",
 }
 
-func getPlatformKey() string {
-	arch := runtime.GOARCH
-	if arch == "amd64" {
-		arch = "x86_64"
-	} else if arch == "386" {
-		arch = "x86"
+// tarError turns a failed tar run into an actionable error. The raw output
+// is kept in the message only when it isn't a known corruption symptom.
+func tarError(err error, out []byte) error {
+	lower := strings.ToLower(string(out))
+	if strings.Contains(lower, "xz: cannot exec") {
+		return fmt.Errorf("tar needs the xz program to unpack .tar.xz, and it is not installed")
 	}
-	
-	os := runtime.GOOS
-	if os == "darwin" {
-		os = "macos"
+	for _, msg := range corruptionMessages {
+		if strings.Contains(lower, msg) {
+			return fmt.Errorf("the tarball appears corrupted or is not valid xz (%s); try --force to re-download, --verbose shows tar's output", msg)
+		}
 	}
-	return fmt.Sprintf("%s-%s", arch, os)
+	return fmt.Errorf("tar extraction failed: %v: %s", err, bytes.TrimSpace(out))
 }
 
-func main() {
-	cfg := getConfig()
+func ensureDirectoryExists(path string) error {
+	return os.MkdirAll(path, 0755)
+}
 
-	if err := checkDependencies(); err != nil {
-		logger.error("%v", err)
-		os.Exit(1)
+// exitWith reports err to log and terminates the process with the
+// matching exit code.
+func exitWith(log Logger, err error) {
+	if _, ok := err.(exitCode); !ok {
+		if l, ok := log.(consoleLogger); ok && l.results {
+			l.record("error", "%v", err)
+			printJSONError(err)
+		} else if ok {
+			l.error("%v", err)
+		} else {
+			log.Error("%v", err)
+		}
 	}
+	os.Exit(exitCodeFor(err))
+}
 
-	// Ensure parent directories exist
-	if err := ensureDirectoryExists(filepath.Dir(cfg.TarDest)); err != nil {
-		logger.error("failed to create tarball directory: %v", err)
-		os.Exit(1)
-	}
+// handleInterrupt exits with exitInterrupted on SIGINT/SIGTERM, removing
+// the partial extraction directory on the way out.
+func handleInterrupt(log Logger, dest func() string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if d := dest(); d != "" {
+			removeDest(d)
+		}
+		exitWith(log, newError(kindInterrupted, "interrupted"))
+	}()
+}
 
-	// Clean up previous files
-	os.Remove(cfg.TarDest)
-	os.RemoveAll(cfg.Dest)
+// run dispatches to a subcommand or the default install flow. The
+// logger configured by the flags replaces *log, which failures are
+// reported to.
+func run(log *Logger, args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd(log, args[1:])
+		}
+	}
 
-	// Fetch release information
-	resp, err := http.Get(cfg.IndexURL)
+	cfg, err := getConfig(log)
 	if err != nil {
-		logger.error("failed to fetch index: %v", err)
-		os.Exit(1)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		logger.error("failed to fetch index: HTTP %d", resp.StatusCode)
-		os.Exit(1)
+	if cfg.Completion != "" {
+		script, err := completionScript(cfg.Completion)
+		if err != nil {
+			return newError(kindUsage, "%v", err)
+		}
+		fmt.Print(script)
+		return nil
 	}
 
-	var index map[string]map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
-		logger.error("failed to parse index: %v", err)
-		os.Exit(1)
+	if cfg.ListInstalled {
+		return printInstalled(cfg)
 	}
 
-	// Get the version info
-	versionInfo, ok := index[cfg.Version]
-	if !ok {
-		logger.error("version %s not found in index", cfg.Version)
-		os.Exit(1)
+	if cfg.Which {
+		return printWhich(cfg)
 	}
 
-	// Get platform-specific release
-	platformKey := getPlatformKey()
-	platformRelease, ok := versionInfo[platformKey].(map[string]interface{})
-	if !ok {
-		logger.error("no release found for platform %s and version %s", platformKey, cfg.Version)
-		os.Exit(1)
+	if cfg.PrintPlatform {
+		return printPlatform(cfg)
 	}
 
-	tarballURL, ok := platformRelease["tarball"].(string)
-	if !ok {
-		logger.error("invalid tarball URL in index")
-		os.Exit(1)
+	if cfg.Platforms {
+		return printPlatforms(cfg)
 	}
 
-	shasum, ok := platformRelease["shasum"].(string)
-	if !ok {
-		logger.error("invalid shasum in index")
-		os.Exit(1)
+	if cfg.VerifyTarball != "" {
+		return verifyTarball(cfg)
 	}
 
-	// Check if we already have a valid tarball
-	needsDownload := true
-	if _, err := os.Stat(cfg.TarDest); err == nil {
-		logger.info("found existing file, checking checksum...")
-		if err := verifyChecksum(cfg.TarDest, shasum); err == nil {
-			logger.success("existing file matches checksum, skipping download")
-			needsDownload = false
-		} else {
-			logger.warning("existing file has incorrect checksum, will download fresh copy")
-			os.Remove(cfg.TarDest)
-		}
+	if len(cfg.Prefetch) > 0 {
+		return prefetch(cfg)
 	}
 
-	// Download tarball if needed
-	if needsDownload {
-		logger.step("downloading Zig %s for %s...", cfg.Version, platformKey)
-		if err := downloadFile(tarballURL, cfg.TarDest); err != nil {
-			logger.error("failed to download tarball: %v", err)
-			os.Exit(1)
-		}
-
-		// Verify checksum of downloaded file
-		logger.step("verifying checksum...")
-		if err := verifyChecksum(cfg.TarDest, shasum); err != nil {
-			os.Remove(cfg.TarDest)
-			logger.error("checksum verification failed: %v", err)
-			os.Exit(1)
+	if cfg.Serve != "" {
+		client, err := newHTTPClient(cfg)
+		if err != nil {
+			return err
 		}
+		return serve(cfg, client)
 	}
 
-	logger.step("extracting...")
-	if err := extractTarball(cfg.TarDest, cfg.Dest); err != nil {
-		logger.error("failed to extract tarball: %v", err)
-		os.Exit(1)
+	if cfg.EphemeralClean {
+		return cleanEphemeral(cfg.log())
 	}
 
-	// Ensure installation directories exist
-	if err := ensureDirectoryExists(cfg.BinDir); err != nil {
-		logger.error("failed to create bin directory: %v", err)
-		os.Exit(1)
-	}
-	if err := ensureDirectoryExists(cfg.LibDir); err != nil {
-		logger.error("failed to create lib directory: %v", err)
-		os.Exit(1)
-	}
-
-	// Install zig
-	logger.step("installing...")
-	os.Remove(filepath.Join(cfg.BinDir, "zig"))
-	os.RemoveAll(filepath.Join(cfg.LibDir, "zig"))
-
-	if err := os.Rename(filepath.Join(cfg.Dest, "zig"), filepath.Join(cfg.BinDir, "zig")); err != nil {
-		logger.error("failed to install zig binary: %v", err)
-		os.Exit(1)
-	}
-
-	// First ensure lib directory exists
-	libSrcPath := filepath.Join(cfg.Dest, "lib")
-	if _, err := os.ReadDir(libSrcPath); err != nil {
-		logger.error("failed to read lib directory: %v", err)
-		os.Exit(1)
+	handleInterrupt(cfg.log(), func() string { return cfg.Dest })
+	cfg.Progress = cfg.log().Progress()
+	if cfg.Ephemeral {
+		return installEphemeral(cfg)
 	}
+	return runInstallReported(cfg)
+}
 
-	// Move the entire lib directory
-	if err := os.Rename(libSrcPath, filepath.Join(cfg.LibDir, "zig")); err != nil {
-		logger.error("failed to install zig libraries: %v", err)
-		os.Exit(1)
+func main() {
+	// Failures before the flags are parsed use the defaults
+	var log Logger = newLogger(Config{Color: colorAuto, ASCII: !unicodeTerminal()})
+	if err := run(&log, os.Args[1:]); err != nil {
+		exitWith(log, err)
 	}
-
-	// Cleanup
-	logger.step("cleaning up...")
-	os.Remove(cfg.TarDest)
-	os.RemoveAll(cfg.Dest)
-
-	logger.success("Zig %s installed successfully! 🎉", cfg.Version)
-}
\ No newline at end of file
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const manifestName = ".zig-installer.json"
+
+// Manifest records what zig-installer put on disk so later runs can tell
+// which concrete version is installed without asking the binary.
+type Manifest struct {
+	Version    string `json:"version"`
+	Requested  string `json:"requested"`
+	Platform   string `json:"platform"`
+	TarballURL string `json:"tarball_url"`
+	Shasum     string `json:"shasum"`
+	BinPath    string `json:"bin_path"`
+	LibPath    string `json:"lib_path"`
+	// Dir is the versioned directory LibPath links to, or LibPath itself
+	// where no link could be made.
+	Dir         string    `json:"dir,omitempty"`
+	InstalledAt time.Time `json:"installed_at"`
+	// Foreign is set when Platform is not the platform of the host that
+	// ran the install (--target).
+	Foreign bool `json:"foreign,omitempty"`
+
+	// ZLS is set when zls was installed for this Zig.
+	ZLS *ZLSInstall `json:"zls,omitempty"`
+
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// manifestPath returns where the manifest for an installed lib tree lives.
+func manifestPath(libPath string) string {
+	return filepath.Join(libPath, manifestName)
+}
+
+// versionDir returns the directory an install of version under name lives
+// in, e.g. <lib-dir>/zig-0.13.0. <lib-dir>/<name> links to it, so the
+// paths Zig and the other commands use stay the same across versions.
+func versionDir(libDir, name, version string) string {
+	return filepath.Join(libDir, name+"-"+version)
+}
+
+// resolveInstall returns the directory the install at libPath lives in:
+// the versioned directory it links to, or libPath itself for installs
+// made before there were versioned directories or without links. Only a
+// relative link to a sibling <name>-<version>, as linkInstall makes, is
+// followed; a link the user made to a tree of their own is not.
+func resolveInstall(libPath string) string {
+	target, err := os.Readlink(libPath)
+	if err != nil || filepath.IsAbs(target) || filepath.Base(target) != target ||
+		!strings.HasPrefix(target, filepath.Base(libPath)+"-") {
+		return libPath
+	}
+	return filepath.Join(filepath.Dir(libPath), target)
+}
+
+// managedInstall is resolveInstall for changes to the install: the
+// versioned directory also has to carry a manifest, so a sibling that
+// merely looks like one is left alone along with everything else the
+// link could point at.
+func managedInstall(libPath string) string {
+	dir := resolveInstall(libPath)
+	if _, err := os.Stat(manifestPath(dir)); dir != libPath && err != nil {
+		return libPath
+	}
+	return dir
+}
+
+// removeInstall removes the install at libPath and the versioned directory
+// it links to. A link to anything else is removed, not what it points at.
+func removeInstall(libPath string) error {
+	if dir := managedInstall(libPath); dir != libPath {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(libPath)
+}
+
+// linkInstall points libPath at the versioned directory dir with a
+// relative link, which keeps working below --root. Where no link can be
+// made, as on Windows without developer mode, dir is renamed to libPath
+// instead.
+func linkInstall(libPath, dir string) error {
+	if err := os.Symlink(filepath.Base(dir), libPath); err == nil {
+		return nil
+	}
+	return os.Rename(dir, libPath)
+}
+
+// checkVersionDir makes sure dir, the versioned directory of a new
+// install, is not taken by the install of another --bin-name, such as
+// --bin-name zig-0.13.0 next to zig 0.13.0.
+func checkVersionDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return nil
+	}
+	m, mErr := readManifest(manifestPath(dir))
+	if info.Mode()&os.ModeSymlink != 0 || mErr == nil && filepath.Base(m.LibPath) == filepath.Base(dir) {
+		return newError(kindFilesystem, "%s belongs to the install under --bin-name %s; pick another --bin-name (nothing was changed)", dir, filepath.Base(dir))
+	}
+	return nil
+}
+
+func writeManifest(path string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func readManifest(path string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// listInstalled returns the manifest of every install found directly
+// below libDir, e.g. <libDir>/zig-0.13.0 that <libDir>/zig links to.
+func listInstalled(libDir string) ([]Manifest, error) {
+	entries, err := os.ReadDir(libDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var installed []Manifest
+	for _, e := range entries {
+		// Installs kept by --keep-old are not managed on their own
+		if !e.IsDir() || strings.HasSuffix(e.Name(), oldSuffix) {
+			continue
+		}
+		dir := filepath.Join(libDir, e.Name())
+		m, err := readManifest(manifestPath(dir))
+		if err != nil {
+			continue
+		}
+		// Left behind by an install that did not finish
+		if resolveInstall(filepath.Join(libDir, filepath.Base(m.LibPath))) != dir {
+			continue
+		}
+		installed = append(installed, m)
+	}
+	return installed, nil
+}
+
+// isActive reports whether m is the install at the default lib location.
+func (m Manifest) isActive(libDir string) bool {
+	return m.LibPath == filepath.Join(libDir, "zig")
+}
+
+// channel returns what an upgrade of m should install: "master" for dev
+// builds and "stable" for tagged releases.
+func (m Manifest) channel() string {
+	if m.Requested == "master" {
+		return "master"
+	}
+	if v, err := parseVersion(m.Version); err == nil && v.isPrerelease() {
+		return "master"
+	}
+	return "stable"
+}
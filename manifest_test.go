@@ -0,0 +1,192 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeInstall creates the versioned directory of an install of version
+// under name below libDir, with its manifest, and links name to it.
+func fakeInstall(t *testing.T, libDir, name, version string) string {
+	t.Helper()
+	dir := versionDir(libDir, name, version)
+	if err := os.MkdirAll(filepath.Join(dir, "std"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := Manifest{Version: version, LibPath: filepath.Join(libDir, name), Dir: dir}
+	if err := writeManifest(manifestPath(dir), m); err != nil {
+		t.Fatal(err)
+	}
+	if err := linkInstall(filepath.Join(libDir, name), dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestVersionDir(t *testing.T) {
+	got := versionDir("/usr/local/lib", "zig", "0.14.0-dev.2345+abcdef")
+	if want := filepath.Join("/usr/local/lib", "zig-0.14.0-dev.2345+abcdef"); got != want {
+		t.Errorf("versionDir = %s, want %s", got, want)
+	}
+}
+
+func TestLinkedInstall(t *testing.T) {
+	libDir := t.TempDir()
+	dir := fakeInstall(t, libDir, "zig", "0.13.0")
+	libPath := filepath.Join(libDir, "zig")
+
+	if got := resolveInstall(libPath); got != dir {
+		t.Errorf("resolveInstall = %s, want %s", got, dir)
+	}
+	if target, err := os.Readlink(libPath); err != nil || target != "zig-0.13.0" {
+		t.Errorf("link points at %q (%v), want the relative zig-0.13.0", target, err)
+	}
+	m, err := readManifest(manifestPath(libPath))
+	if err != nil || m.Version != "0.13.0" {
+		t.Errorf("manifest through the link: %+v, %v", m, err)
+	}
+
+	if err := removeInstall(libPath); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{libPath, dir} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s survived removeInstall", path)
+		}
+	}
+}
+
+func TestRemoveInstallKeepsForeignLinkTargets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	tests := []struct {
+		name   string
+		target func(libDir, src string) string
+	}{
+		{"absolute", func(libDir, src string) string { return src }},
+		{"relative outside", func(libDir, src string) string { return filepath.Join("..", "src", "lib") }},
+		// Looks like a versioned directory, but has no manifest
+		{"sibling without manifest", func(libDir, src string) string {
+			os.MkdirAll(filepath.Join(libDir, "zig-master", "std"), 0755)
+			return "zig-master"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			libDir, src := filepath.Join(root, "lib"), filepath.Join(root, "src", "lib")
+			fakeBinary(t, filepath.Join(src, "std", "std.zig"), "std")
+			os.MkdirAll(libDir, 0755)
+			libPath := filepath.Join(libDir, "zig")
+			target := tt.target(libDir, src)
+			if err := os.Symlink(target, libPath); err != nil {
+				t.Fatal(err)
+			}
+			dir := filepath.Join(libDir, target)
+			if filepath.IsAbs(target) {
+				dir = target
+			}
+
+			if got := managedInstall(libPath); got != libPath {
+				t.Errorf("managedInstall = %s, want the link itself", got)
+			}
+			if err := removeInstall(libPath); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Lstat(libPath); !os.IsNotExist(err) {
+				t.Error("the link survived removeInstall")
+			}
+			if _, err := os.Stat(filepath.Join(dir, "std")); err != nil {
+				t.Errorf("the tree the link pointed at was removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeepOldMovesForeignLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	root := t.TempDir()
+	binPath, libPath := filepath.Join(root, "bin", "zig"), filepath.Join(root, "lib", "zig")
+	src := filepath.Join(root, "src", "lib")
+	fakeBinary(t, filepath.Join(src, "std", "std.zig"), "std")
+	fakeBinary(t, binPath, "dev")
+	os.MkdirAll(filepath.Dir(libPath), 0755)
+	if err := os.Symlink(src, libPath); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := keepOld(binPath, libPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(libPath + oldSuffix); err != nil || target != src {
+		t.Errorf("kept %s as %q, %v; want the link itself", libPath+oldSuffix, target, err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "std", "std.zig")); err != nil {
+		t.Errorf("the tree the link pointed at was moved: %v", err)
+	}
+	if err := b.restore(binPath, libPath); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(libPath); err != nil || target != src {
+		t.Errorf("restored %s as %q, %v", libPath, target, err)
+	}
+}
+
+func TestResolveInstallUnlinked(t *testing.T) {
+	libPath := filepath.Join(t.TempDir(), "zig")
+	if err := os.Mkdir(libPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := resolveInstall(libPath); got != libPath {
+		t.Errorf("resolveInstall of a plain directory = %s, want it unchanged", got)
+	}
+}
+
+func TestListInstalledSkipsUnlinkedVersions(t *testing.T) {
+	libDir := t.TempDir()
+	fakeInstall(t, libDir, "zig", "0.13.0")
+	fakeInstall(t, libDir, "zig-nightly", "0.14.0-dev.1+abc")
+	// An older version the link no longer points at
+	stale := versionDir(libDir, "zig", "0.12.0")
+	os.MkdirAll(stale, 0755)
+	writeManifest(manifestPath(stale), Manifest{Version: "0.12.0", LibPath: filepath.Join(libDir, "zig")})
+
+	installed, err := ListInstalled(libDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 2 {
+		t.Fatalf("listed %d installs, want 2: %+v", len(installed), installed)
+	}
+	for _, v := range installed {
+		if v.Version == "0.12.0" {
+			t.Errorf("listed the stale %s", v.Dir)
+		}
+		if v.Active != (v.Name == "zig") {
+			t.Errorf("%s is active: %v", v.Name, v.Active)
+		}
+	}
+}
+
+func TestCheckVersionDir(t *testing.T) {
+	libDir := t.TempDir()
+	fakeInstall(t, libDir, "zig", "0.12.0")
+	// Installed under --bin-name zig-0.13.0
+	fakeInstall(t, libDir, "zig-0.13.0", "0.13.0")
+
+	if err := checkVersionDir(versionDir(libDir, "zig", "0.12.0")); err != nil {
+		t.Errorf("the versioned directory of the install itself was refused: %v", err)
+	}
+	if err := checkVersionDir(versionDir(libDir, "zig", "0.11.0")); err != nil {
+		t.Errorf("a free directory was refused: %v", err)
+	}
+	if err := checkVersionDir(versionDir(libDir, "zig", "0.13.0")); err == nil {
+		t.Error("the link of --bin-name zig-0.13.0 was accepted as a versioned directory")
+	}
+}
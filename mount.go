@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// mountPoints returns the mount points listed in /proc/self/mountinfo. It
+// returns nil outside Linux or when the file cannot be read.
+func mountPoints() []string {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil
+	}
+	var points []string
+	for _, line := range strings.Split(string(data), "\n") {
+		// The fifth field is the mount point, with spaces and the
+		// like escaped in octal
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		points = append(points, unescapeMountPath(fields[4]))
+	}
+	return points
+}
+
+// unescapeMountPath decodes the \040 style escapes of mountinfo paths.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// mountAt returns the mount point at or below path, which removing and
+// renaming path would fail on, or "".
+func mountAt(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	for _, point := range mountPoints() {
+		if point == abs || strings.HasPrefix(point, abs+string(filepath.Separator)) {
+			return point
+		}
+	}
+	return ""
+}
+
+// checkReplaceable makes sure the previous install at libPath can be
+// removed before anything is touched, so an install into a mount point
+// fails up front instead of halfway.
+func checkReplaceable(libPath string) error {
+	if _, err := os.Lstat(libPath); err != nil {
+		return nil
+	}
+	if point := mountAt(libPath); point != "" {
+		return newError(kindFilesystem, "%s cannot be replaced because %s is a mount point; unmount it, or install to a different --lib-dir (nothing was changed)", libPath, point)
+	}
+	return nil
+}
+
+// removeFailedHint explains a previous install that could not be removed.
+func removeFailedHint(libPath string, err error) error {
+	if errors.Is(err, syscall.EBUSY) {
+		return newError(kindFilesystem, "failed to remove the previous install at %s: %v; something is mounted there or holds it open, so unmount it or install to a different --lib-dir", libPath, err)
+	}
+	return newError(kindFilesystem, "failed to remove the previous install at %s: %v", libPath, err)
+}
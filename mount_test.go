@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestUnescapeMountPath(t *testing.T) {
+	tests := map[string]string{
+		"/usr/local/lib":        "/usr/local/lib",
+		`/mnt/my\040disk`:       "/mnt/my disk",
+		`/mnt/tab\011and\012nl`: "/mnt/tab\tand\nnl",
+		`/mnt/back\134slash`:    `/mnt/back\slash`,
+		`/mnt/trailing\04`:      `/mnt/trailing\04`,
+		`/mnt/not\999octal`:     `/mnt/not\999octal`,
+		`/mnt/too\400big`:       `/mnt/too\400big`,
+		`\040leading`:           " leading",
+		`/mnt/end\040`:          "/mnt/end ",
+	}
+	for in, want := range tests {
+		if got := unescapeMountPath(in); got != want {
+			t.Errorf("unescapeMountPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCheckReplaceable(t *testing.T) {
+	libPath := filepath.Join(t.TempDir(), "zig")
+	if err := checkReplaceable(libPath); err != nil {
+		t.Errorf("a missing lib directory is not replaceable: %v", err)
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	// The root directory is a mount point on every Linux system
+	err := checkReplaceable("/")
+	if errorKindOf(err) != kindFilesystem || !strings.Contains(err.Error(), "is a mount point") {
+		t.Errorf("checkReplaceable(/) = %v, want a mount point error", err)
+	}
+}
+
+func TestRemoveFailedHint(t *testing.T) {
+	err := removeFailedHint("/usr/local/lib/zig", syscall.EBUSY)
+	if errorKindOf(err) != kindFilesystem || !strings.Contains(err.Error(), "unmount it") {
+		t.Errorf("EBUSY hint %v", err)
+	}
+	err = removeFailedHint("/usr/local/lib/zig", errors.New("boom"))
+	if strings.Contains(err.Error(), "unmount") {
+		t.Errorf("hint for an unrelated error mentions mounts: %v", err)
+	}
+}
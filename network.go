@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// networkPollInterval is how long --wait-for-network sleeps between polls.
+const networkPollInterval = 2 * time.Second
+
+// waitForNetwork polls url with HEAD requests until the server answers,
+// whatever the status, or timeout elapses. Any response means DNS and
+// routing work, which is all it waits for.
+func waitForNetwork(log Logger, client *http.Client, url string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(http.MethodHead, url, nil)
+		if err != nil {
+			return newError(kindUsage, "invalid index URL %q: %v", url, err)
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if attempt > 1 {
+				log.Info("%s is reachable", url)
+			}
+			return nil
+		}
+		log.Debug("waiting for network, attempt %d: %v", attempt, err)
+
+		if time.Now().Add(networkPollInterval).After(deadline) {
+			return newError(kindNetwork, "%s still unreachable after %s: %v", url, timeout, err)
+		}
+		time.Sleep(networkPollInterval)
+	}
+}
+
+// awaitIndex applies --wait-for-network to the index cfg.Version is read
+// from.
+func awaitIndex(cfg Config, client *http.Client) error {
+	if cfg.WaitForNetwork <= 0 || cfg.FromLock != "" {
+		return nil
+	}
+	url, err := indexURLFor(cfg, cfg.Version)
+	if err != nil {
+		return err
+	}
+	if urls := splitList(url); len(urls) > 0 {
+		url = urls[0]
+	}
+	return waitForNetwork(cfg.log(), client, url, cfg.WaitForNetwork)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// maxNotesSize caps how much of a release notes page is read.
+const maxNotesSize = 32 << 20
+
+func runNotes(log *Logger, args []string) error {
+	var cfg Config
+	var fetch bool
+	fs := newCommandFlags("notes", "notes [flags] <version>", &cfg)
+	fs.BoolVar(&fetch, "fetch", false, "Download the release notes and show them as plain text")
+	positional, err := parseCommand(log, fs, &cfg, args)
+	if err != nil {
+		return err
+	}
+	requested := cfg.Version
+	if len(positional) > 0 {
+		requested = positional[0]
+	}
+
+	index, err := loadIndex(cfg, requested)
+	if err != nil {
+		return err
+	}
+	key, err := resolveVersion(index, requested, cfg.Prerelease)
+	if err != nil {
+		return err
+	}
+	notes := index[key].Notes
+
+	if cfg.JSON {
+		return printJSON(struct {
+			Version string `json:"version"`
+			Notes   string `json:"notes"`
+		}{key, notes})
+	}
+	if notes == "" {
+		fmt.Printf("Zig %s has no release notes (only tagged releases do)\n", key)
+		return nil
+	}
+	if !fetch {
+		fmt.Println(notes)
+		return nil
+	}
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Get(notes)
+	if err != nil {
+		return newError(kindNetwork, "failed to fetch release notes: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newError(kindNetwork, "failed to fetch release notes: HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxNotesSize))
+	if err != nil {
+		return newError(kindNetwork, "failed to fetch release notes: %v", err)
+	}
+	return page(renderHTML(string(body)))
+}
+
+// page shows text through $PAGER (less by default) when stdout is a
+// terminal, and prints it directly otherwise.
+func page(text string) error {
+	if !isTerminal(os.Stdout) {
+		_, err := io.WriteString(os.Stdout, text)
+		return err
+	}
+	pager := getEnv("PAGER", "less")
+	fields := strings.Fields(pager)
+	if len(fields) == 0 {
+		_, err := io.WriteString(os.Stdout, text)
+		return err
+	}
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// No usable pager; don't lose the output over it
+		_, err = io.WriteString(os.Stdout, text)
+		return err
+	}
+	return nil
+}
+
+var (
+	htmlTagPattern   = regexp.MustCompile(`(?s)<(/?)([a-zA-Z0-9]+)[^>]*>|<!--.*?-->`)
+	htmlSkipPattern  = regexp.MustCompile(`(?is)<(script|style|head|nav)\b.*?</(script|style|head|nav)>`)
+	blankRunsPattern = regexp.MustCompile(`\n{3,}`)
+	spaceRunsPattern = regexp.MustCompile(`\s+`)
+)
+
+// renderHTML turns a release notes page into readable plain text: tags
+// are stripped, headings are kept as markdown style "#" lines, list items
+// get a dash and <pre> blocks keep their whitespace, indented.
+func renderHTML(page string) string {
+	page = htmlSkipPattern.ReplaceAllString(page, "")
+
+	var b strings.Builder
+	pre := 0
+	last := 0
+	text := func(s string) {
+		s = html.UnescapeString(s)
+		if pre > 0 {
+			b.WriteString(strings.ReplaceAll(s, "\n", "\n    "))
+			return
+		}
+		b.WriteString(spaceRunsPattern.ReplaceAllString(s, " "))
+	}
+
+	for _, m := range htmlTagPattern.FindAllStringSubmatchIndex(page, -1) {
+		text(page[last:m[0]])
+		last = m[1]
+		if m[4] < 0 {
+			continue // comment
+		}
+		closing := m[3] > m[2]
+		switch tag := strings.ToLower(page[m[4]:m[5]]); tag {
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			if closing {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteString("\n\n" + strings.Repeat("#", int(tag[1]-'0')) + " ")
+			}
+		case "pre":
+			if closing {
+				pre--
+				b.WriteString("\n\n")
+			} else {
+				pre++
+				b.WriteString("\n\n    ")
+			}
+		case "p", "div", "section", "table", "tr", "ul", "ol", "dl":
+			b.WriteString("\n\n")
+		case "br":
+			b.WriteString("\n")
+		case "li", "dt":
+			if !closing {
+				b.WriteString("\n- ")
+			}
+		case "code":
+			if pre == 0 {
+				b.WriteString("`")
+			}
+		}
+	}
+	text(page[last:])
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "    ") {
+			lines[i] = strings.TrimRight(line, " ")
+		} else {
+			lines[i] = strings.TrimSpace(line)
+		}
+	}
+	out := blankRunsPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(out) + "\n"
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// outdatedEntry compares one installed version with the index.
+type outdatedEntry struct {
+	Installed string `json:"installed"`
+	Latest    string `json:"latest"`
+	Channel   string `json:"channel"`
+	LibPath   string `json:"lib_path"`
+	Active    bool   `json:"active"`
+	Outdated  bool   `json:"outdated"`
+}
+
+// checkOutdated compares every install below cfg.LibDir with the index.
+func checkOutdated(cfg Config) ([]outdatedEntry, []Manifest, error) {
+	installed, err := listInstalled(cfg.rooted(cfg.LibDir))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indexes := make(map[string]Index)
+	var entries []outdatedEntry
+	for _, m := range installed {
+		url, err := indexURLFor(cfg, m.Requested)
+		if err != nil {
+			return nil, nil, err
+		}
+		index, ok := indexes[url]
+		if !ok {
+			index, err = loadIndex(cfg, m.Requested)
+			if err != nil {
+				return nil, nil, err
+			}
+			indexes[url] = index
+		}
+
+		e := outdatedEntry{
+			Installed: m.Version,
+			Channel:   m.channel(),
+			LibPath:   m.LibPath,
+			Active:    m.isActive(cfg.LibDir),
+		}
+		latest, err := resolveVersion(index, e.Channel, cfg.Prerelease)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v := index[latest].Version; v != "" {
+			latest = v
+		}
+		e.Latest = latest
+		e.Outdated = isNewerVersion(latest, m.Version)
+		entries = append(entries, e)
+	}
+	return entries, installed, nil
+}
+
+func runOutdated(log *Logger, args []string) error {
+	var cfg Config
+	fs := newCommandFlags("outdated", "outdated [flags]", &cfg)
+	if _, err := parseCommand(log, fs, &cfg, args); err != nil {
+		return err
+	}
+
+	entries, _, err := checkOutdated(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.JSON {
+		if entries == nil {
+			entries = []outdatedEntry{}
+		}
+		return printJSON(entries)
+	}
+	if len(entries) == 0 {
+		cfg.log().Info("no installs found in %s", cfg.LibDir)
+		return nil
+	}
+
+	for _, e := range entries {
+		marker := " "
+		if e.Active {
+			marker = "*"
+		}
+		status := "up to date"
+		if e.Outdated {
+			status = "update available: " + e.Latest
+		}
+		fmt.Printf("%s %-28s %-7s %s\n", marker, e.Installed, e.Channel, status)
+	}
+	return nil
+}
+
+func runUpgrade(log *Logger, args []string) error {
+	var cfg Config
+	var all bool
+	fs := newCommandFlags("upgrade", "upgrade [flags] [version|--all]", &cfg)
+	fs.BoolVar(&all, "all", false, "Upgrade every installed version")
+	positional, err := parseCommand(log, fs, &cfg, args)
+	if err != nil {
+		return err
+	}
+
+	entries, installed, err := checkOutdated(cfg)
+	if err != nil {
+		return err
+	}
+
+	var summary []string
+	attempted, failed := 0, 0
+	for i, e := range entries {
+		m := installed[i]
+		switch {
+		case all:
+		case len(positional) > 0:
+			if positional[0] != m.Version && positional[0] != m.Requested {
+				continue
+			}
+		case !e.Active:
+			continue
+		}
+
+		if !e.Outdated {
+			summary = append(summary, fmt.Sprintf("%s: already current", m.Version))
+			continue
+		}
+
+		upgrade := cfg
+		upgrade.Version = e.Channel
+		upgrade.BinDir = filepath.Dir(m.BinPath)
+		upgrade.LibDir = filepath.Dir(m.LibPath)
+		upgrade.BinName = filepath.Base(m.LibPath)
+		upgrade.Progress = cfg.log().Progress()
+		cfg.log().Step("upgrading %s to %s...", m.Version, e.Latest)
+		attempted++
+		if err := runInstall(upgrade); err != nil {
+			err = fmt.Errorf("failed to upgrade %s: %v", m.Version, err)
+			if !cfg.KeepGoing {
+				return err
+			}
+			cfg.log().Error("%v", err)
+			summary = append(summary, fmt.Sprintf("%s: failed", m.Version))
+			failed++
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%s → %s", m.Version, e.Latest))
+
+		// Follow up with the zls matching the new Zig
+		if m.ZLS != nil {
+			attempted++
+			if err := upgradeZLS(upgrade, m.ZLS); err != nil {
+				err = fmt.Errorf("failed to upgrade zls for %s: %v", e.Latest, err)
+				if !cfg.KeepGoing {
+					return err
+				}
+				cfg.log().Error("%v", err)
+				summary = append(summary, fmt.Sprintf("zls for %s: failed", e.Latest))
+				failed++
+			}
+		}
+	}
+
+	if len(summary) == 0 {
+		if len(positional) > 0 {
+			return fmt.Errorf("version %s is not installed in %s", positional[0], cfg.LibDir)
+		}
+		cfg.log().Info("nothing to upgrade")
+		return nil
+	}
+	for _, line := range summary {
+		cfg.log().Info("%s", line)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d upgrades failed", failed, attempted)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// profileMarker ends the lines --update-path writes, so they can be told
+// apart from the user's own.
+const profileMarker = "# added by zig-installer"
+
+// updatePath makes dir part of the user's PATH for new shells: through the
+// user environment in the registry on Windows, through the login shell's
+// profile elsewhere. It reports what it changed.
+func updatePath(log Logger, dir string) error {
+	if runtime.GOOS == "windows" {
+		return updateWindowsPath(log, dir)
+	}
+
+	shell := detectShell()
+	profile, err := shellProfile(shell)
+	if err != nil {
+		return err
+	}
+	line := pathLine(shell, dir)
+
+	data, err := os.ReadFile(profile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, l := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(l) == line {
+			log.Info("%s already adds %s to PATH", profile, dir)
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(profile), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(profile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	prefix := ""
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		prefix = "\n"
+	}
+	if _, err := fmt.Fprintf(f, "%s%s\n", prefix, line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Info("appended to %s: %s", profile, line)
+	source := "."
+	if shell == "fish" {
+		source = "source"
+	}
+	log.Info("open a new shell or run: %s %s", source, profile)
+	return nil
+}
+
+// shellProfile returns the startup file of shell that PATH changes belong
+// in.
+func shellProfile(shell string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch shell {
+	case "bash":
+		// Terminal on macOS starts login shells, which skip .bashrc
+		if runtime.GOOS == "darwin" {
+			return filepath.Join(home, ".bash_profile"), nil
+		}
+		return filepath.Join(home, ".bashrc"), nil
+	case "zsh":
+		return filepath.Join(getEnv("ZDOTDIR", home), ".zshrc"), nil
+	case "fish":
+		return filepath.Join(getEnv("XDG_CONFIG_HOME", filepath.Join(home, ".config")), "fish", "conf.d", "zig-installer.fish"), nil
+	}
+	return filepath.Join(home, ".profile"), nil
+}
+
+// pathLine is the profile line that prepends dir to PATH in shell.
+func pathLine(shell, dir string) string {
+	if shell == "fish" {
+		return fmt.Sprintf("fish_add_path --global %s %s", fishQuote(dir), profileMarker)
+	}
+	return fmt.Sprintf("export PATH=%s:\"$PATH\" %s", shQuote(dir), profileMarker)
+}
+
+// shQuote quotes s for POSIX shells.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for fish, which escapes quotes inside single quotes.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// updateWindowsPath appends dir to the user's Path in the registry, like
+// the System Properties dialog does. PowerShell is used so the change is
+// announced to newly started programs.
+func updateWindowsPath(log Logger, dir string) error {
+	script := `$dir = $args[0]
+$path = [Environment]::GetEnvironmentVariable('Path', 'User')
+$parts = @($path -split ';' | Where-Object { $_ -ne '' })
+if ($parts -contains $dir) { 'present'; exit 0 }
+[Environment]::SetEnvironmentVariable('Path', (($parts + $dir) -join ';'), 'User')
+'added'`
+	out, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script, dir).Output()
+	if err != nil {
+		return fmt.Errorf("failed to update the user Path: %v", err)
+	}
+	if strings.TrimSpace(string(out)) == "present" {
+		log.Info("the user Path already contains %s", dir)
+		return nil
+	}
+	log.Info("appended %s to the user Path in HKCU\\Environment; open a new terminal to pick it up", dir)
+	return nil
+}
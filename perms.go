@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// modeFlag is a flag.Value holding an octal permission such as 0755.
+type modeFlag struct {
+	mode *os.FileMode
+}
+
+func (f modeFlag) String() string {
+	if f.mode == nil {
+		return ""
+	}
+	return fmt.Sprintf("%04o", uint32(*f.mode))
+}
+
+func (f modeFlag) Set(s string) error {
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m > 0777 {
+		return fmt.Errorf("invalid mode %q: expected octal permissions such as 0755", s)
+	}
+	*f.mode = os.FileMode(m)
+	return nil
+}
+
+// ensureInstallDir creates path like os.MkdirAll but sets dirMode
+// explicitly on every directory it creates, so the umask has no say.
+func ensureInstallDir(path string, dirMode os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s exists and is not a directory", path)
+		}
+		return nil
+	}
+	if err := ensureInstallDir(filepath.Dir(path), dirMode); err != nil {
+		return err
+	}
+	if err := os.Mkdir(path, dirMode); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return os.Chmod(path, dirMode)
+}
+
+// applyInstallModes sets fileMode on the installed binary and walks the
+// installed lib tree setting dirMode on directories and fileMode without
+// execute bits on regular files.
+func applyInstallModes(binPath, libPath string, fileMode, dirMode os.FileMode) error {
+	if err := os.Chmod(binPath, fileMode); err != nil {
+		return err
+	}
+	return filepath.WalkDir(libPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.Chmod(path, dirMode)
+		case d.Type().IsRegular():
+			return os.Chmod(path, fileMode&^0111)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModeFlagSet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want os.FileMode
+		ok   bool
+	}{
+		{"0755", 0755, true},
+		{"755", 0755, true},
+		{"0", 0, true},
+		{"0777", 0777, true},
+		{"1777", 0, false},
+		{"0778", 0, false},
+		{"rwxr-xr-x", 0, false},
+		{"-1", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		var mode os.FileMode
+		err := modeFlag{&mode}.Set(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && mode != tt.want {
+			t.Errorf("Set(%q) = %04o, want %04o", tt.in, mode, tt.want)
+		}
+	}
+	mode := os.FileMode(0750)
+	if got := (modeFlag{&mode}).String(); got != "0750" {
+		t.Errorf("String() = %s, want 0750", got)
+	}
+}
+
+func TestInstallAppliesModes(t *testing.T) {
+	srv := serveReleases(t, map[string]string{"0.13.0": "0.13.0"})
+	cfg := testConfig(t, srv.URL+"/index.json", "-version", "0.13.0", "-file-mode", "0750", "-dir-mode", "0710")
+	cfg.Logger = silentLogger{}
+	if err := runInstall(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(path string, want os.FileMode) {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s has mode %04o, want %04o", path, got, want)
+		}
+	}
+	check(filepath.Join(cfg.BinDir, "zig"), 0750)
+	check(cfg.BinDir, 0710)
+	check(cfg.LibDir, 0710)
+	dir := resolveInstall(filepath.Join(cfg.LibDir, "zig"))
+	check(dir, 0710)
+	check(filepath.Join(dir, "std"), 0710)
+	check(filepath.Join(dir, "std", "std.zig"), 0640)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// zigArchs maps GOARCH values to the architecture names used in index
+// keys. A GOARCH missing here has no Zig builds at all.
+var zigArchs = map[string]string{
+	"amd64":   "x86_64",
+	"386":     "x86",
+	"arm64":   "aarch64",
+	"riscv64": "riscv64",
+	"ppc64le": "powerpc64le",
+	"s390x":   "s390x",
+	"loong64": "loongarch64",
+}
+
+// zigOSes maps GOOS values to the OS names used in index keys. A GOOS
+// missing here is passed through unchanged.
+var zigOSes = map[string]string{
+	"darwin":  "macos",
+	"android": "linux", // Termux runs the regular Linux builds
+	"linux":   "linux",
+	"windows": "windows",
+	"freebsd": "freebsd",
+	"netbsd":  "netbsd",
+}
+
+// termuxDefaultPrefix is $PREFIX in a standard Termux install.
+const termuxDefaultPrefix = "/data/data/com.termux/files/usr"
+
+// termuxPrefix returns Termux's $PREFIX when running inside Termux, where
+// everything lives below it and /usr/local and /tmp don't exist, or ""
+// elsewhere.
+func termuxPrefix() string {
+	prefix := os.Getenv("PREFIX")
+	if os.Getenv("TERMUX_VERSION") == "" && !strings.Contains(prefix, "/com.termux/") {
+		return ""
+	}
+	if prefix == "" {
+		prefix = termuxDefaultPrefix
+	}
+	return prefix
+}
+
+// defaultDirs returns the default install prefix and scratch directory:
+// /usr/local and /tmp, or their counterparts below $PREFIX on Termux.
+func defaultDirs() (prefix, tmp string) {
+	if p := termuxPrefix(); p != "" {
+		return p, filepath.Join(p, "tmp")
+	}
+	return "/usr/local", "/tmp"
+}
+
+// systemSharePrefix is where packages put shared data such as shell
+// completions. The BSDs keep third-party files out of /usr/share.
+func systemSharePrefix() string {
+	switch runtime.GOOS {
+	case "freebsd", "openbsd", "dragonfly":
+		return "/usr/local/share"
+	case "netbsd":
+		return "/usr/pkg/share"
+	}
+	return "/usr/share"
+}
+
+// underRosetta reports whether this process is an amd64 build translated
+// by Rosetta on Apple Silicon. The answer is cached, as the host key is
+// looked up for several flag defaults.
+var underRosetta = sync.OnceValue(func() bool {
+	if runtime.GOOS != "darwin" || runtime.GOARCH != "amd64" {
+		return false
+	}
+	out, err := exec.Command("sysctl", "-n", "sysctl.proc_translated").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "1"
+})
+
+// hostInfo is everything platform detection looks at, gathered once so
+// each detection rule is a function of it rather than of the machine.
+type hostInfo struct {
+	GOOS   string
+	GOARCH string
+	// Rosetta is set for an amd64 process translated on Apple Silicon
+	Rosetta bool
+	// ARMVariants are the 32-bit ARM builds the CPU runs, best first
+	ARMVariants []string
+	Libc        string
+	Termux      bool
+}
+
+// currentHost is the hostInfo of this machine.
+var currentHost = sync.OnceValue(func() hostInfo {
+	h := hostInfo{
+		GOOS:    runtime.GOOS,
+		GOARCH:  runtime.GOARCH,
+		Rosetta: underRosetta(),
+		Libc:    hostLibc(),
+		Termux:  termuxPrefix() != "",
+	}
+	if h.GOARCH == "arm" {
+		h.ARMVariants = hostARMVariants()
+	}
+	return h
+})
+
+// platformModifier is a detection rule applied on top of the zigOSes and
+// zigArchs tables. apply returns the key to use and a note on what it
+// detected, or an empty note when the rule doesn't apply to the host.
+type platformModifier struct {
+	name  string
+	apply func(h hostInfo, key string) (string, string)
+	// namesArch is set for rules that pick the architecture themselves,
+	// which settles a GOARCH the tables have no name for.
+	namesArch bool
+}
+
+// platformModifiers run in order, each seeing the key the previous left.
+var platformModifiers = []platformModifier{
+	{"rosetta", rosettaModifier, true},
+	{"arm", armModifier, true},
+	{"termux", termuxModifier, false},
+	{"musl", muslModifier, false},
+}
+
+// platformNote is a platform modifier that applied, as --print-platform
+// shows it.
+type platformNote struct {
+	Rule string `json:"rule"`
+	Note string `json:"note"`
+}
+
+// rosettaModifier picks the native build for an x86_64 installer running
+// under Rosetta, since a native Zig is much faster than an emulated one.
+func rosettaModifier(h hostInfo, key string) (string, string) {
+	if h.GOOS != "darwin" || h.GOARCH != "amd64" || !h.Rosetta {
+		return key, ""
+	}
+	_, os := splitPlatformKey(key)
+	return zigArchs["arm64"] + "-" + os, "x86_64 installer translated by Rosetta on an arm64 CPU"
+}
+
+// armModifier names the 32-bit ARM variant the CPU runs, which GOARCH
+// alone can't tell.
+func armModifier(h hostInfo, key string) (string, string) {
+	if h.GOARCH != "arm" || len(h.ARMVariants) == 0 {
+		return key, ""
+	}
+	_, os := splitPlatformKey(key)
+	return h.ARMVariants[0] + "-" + os, fmt.Sprintf("32-bit ARM CPU runs %s builds", strings.Join(h.ARMVariants, ", "))
+}
+
+// termuxModifier only notes Termux: zigOSes already maps android to the
+// Linux builds it runs.
+func termuxModifier(h hostInfo, key string) (string, string) {
+	if !h.Termux {
+		return key, ""
+	}
+	return key, "Termux on Android, which runs the Linux builds"
+}
+
+// muslModifier only notes a musl host; the Linux builds are statically
+// linked and run on either libc.
+func muslModifier(h hostInfo, key string) (string, string) {
+	if h.Libc != "musl" {
+		return key, ""
+	}
+	return key, "musl libc host, the static Linux builds run on it"
+}
+
+// detectPlatform maps h to an index key through the tables and then the
+// modifiers, returning the notes of those that applied. An unknown GOARCH
+// is an error unless a modifier names the architecture.
+func detectPlatform(h hostInfo) (string, []platformNote, error) {
+	key, err := platformKeyFor(h.GOOS, h.GOARCH)
+	var notes []platformNote
+	for _, m := range platformModifiers {
+		adjusted, note := m.apply(h, key)
+		if note == "" {
+			continue
+		}
+		if m.namesArch {
+			err = nil
+		}
+		key = adjusted
+		notes = append(notes, platformNote{Rule: m.name, Note: note})
+	}
+	return key, notes, err
+}
+
+// getPlatformKey returns the index key for the host, e.g. x86_64-linux.
+// On an architecture Zig has no name for it falls back to the raw GOARCH,
+// which is fine for display; installs go through hostPlatformKey.
+func getPlatformKey() string {
+	key, _ := hostPlatformKey()
+	return key
+}
+
+// hostPlatformKey is getPlatformKey for picking an artifact: it fails on
+// architectures that can never match an index key.
+func hostPlatformKey() (string, error) {
+	key, _, err := detectPlatform(currentHost())
+	return key, err
+}
+
+// processPlatformKey is the platform the installer itself was built for,
+// which only differs from the host's under Rosetta.
+func processPlatformKey() (string, error) {
+	return platformKeyFor(runtime.GOOS, runtime.GOARCH)
+}
+
+// runsOnHost reports whether builds for key can run here, natively or
+// translated like the installer itself.
+func runsOnHost(key string) bool {
+	process, _ := processPlatformKey()
+	return key == getPlatformKey() || key == process
+}
+
+// rosettaNotice says once per run that Rosetta was seen through, however
+// many times the target is worked out.
+var rosettaNotice sync.Once
+
+// targetPlatformKey is the platform to install for: --target when given,
+// otherwise the host with --os and --arch replacing their half of the key.
+func targetPlatformKey(cfg Config) (string, error) {
+	if cfg.Target != "" {
+		if cfg.OS != "" || cfg.Arch != "" {
+			return "", newError(kindUsage, "--target cannot be combined with --os or --arch")
+		}
+		return cfg.Target, nil
+	}
+	key, err := hostPlatformKey()
+	if cfg.PreferHostArch {
+		key, err = processPlatformKey()
+	} else if underRosetta() && cfg.Arch == "" {
+		rosettaNotice.Do(func() {
+			cfg.log().Info("running under Rosetta, picking the native %s build; pass --prefer-host-arch for x86_64", key)
+		})
+	}
+	if cfg.OS == "" && cfg.Arch == "" {
+		return key, err
+	}
+	arch, os := splitPlatformKey(key)
+	if cfg.Arch != "" {
+		arch, err = zigName(cfg.Arch, zigArchs), nil
+	}
+	if err != nil {
+		// The raw key still goes along for messages
+		return arch + "-" + zigName(cfg.OS, zigOSes), err
+	}
+	if cfg.OS != "" {
+		os = zigName(cfg.OS, zigOSes)
+	}
+	return arch + "-" + os, nil
+}
+
+// binaryFile is the file name of an executable called name in builds for
+// platformKey: zig.exe rather than zig on Windows. Installing, checking
+// the extracted tree and locating the binary all go through it.
+func binaryFile(name, platformKey string) string {
+	if isWindowsKey(platformKey) && !strings.HasSuffix(name, ".exe") {
+		return name + ".exe"
+	}
+	return name
+}
+
+// isWindowsKey reports whether platformKey is a Windows platform, whose
+// executables carry no permission bits.
+func isWindowsKey(platformKey string) bool {
+	_, os := splitPlatformKey(platformKey)
+	return os == "windows"
+}
+
+// splitPlatformKey splits an index key such as x86_64-linux into its
+// architecture and OS.
+func splitPlatformKey(key string) (arch, os string) {
+	arch, os, _ = strings.Cut(key, "-")
+	return arch, os
+}
+
+// zigName accepts either the index name or the Go name of an --os or
+// --arch value, so both aarch64 and arm64 work.
+func zigName(value string, table map[string]string) string {
+	if name, ok := table[value]; ok {
+		return name
+	}
+	return value
+}
+
+// checkPlatformAxes rejects --os and --arch values that no platform key
+// in the index uses, listing the ones it does.
+func checkPlatformAxes(cfg Config, idx Index) error {
+	if cfg.OS == "" && cfg.Arch == "" {
+		return nil
+	}
+	arches := make(map[string]bool)
+	oses := make(map[string]bool)
+	for key := range idx.versionsByPlatform() {
+		arch, os := splitPlatformKey(key)
+		arches[arch] = true
+		oses[os] = true
+	}
+	if cfg.Arch != "" {
+		if err := checkAxis("--arch", zigName(cfg.Arch, zigArchs), arches); err != nil {
+			return err
+		}
+	}
+	if cfg.OS != "" {
+		return checkAxis("--os", zigName(cfg.OS, zigOSes), oses)
+	}
+	return nil
+}
+
+func checkAxis(flag, value string, seen map[string]bool) error {
+	if seen[value] {
+		return nil
+	}
+	valid := make([]string, 0, len(seen))
+	for v := range seen {
+		valid = append(valid, v)
+	}
+	sort.Strings(valid)
+	return newError(kindUsage, "unknown %s value %q; the index has: %s", flag, value, strings.Join(valid, ", "))
+}
+
+// unsupportedArchError is a GOARCH that has no name in zigArchs, so no
+// index key can match it.
+type unsupportedArchError struct {
+	goarch string
+}
+
+func (e *unsupportedArchError) Error() string {
+	return fmt.Sprintf("unsupported architecture: GOARCH %q has no Zig builds", e.goarch)
+}
+
+// platformIn is targetPlatformKey for looking up version in idx: a host
+// architecture Zig has no name for gets the same platformError as any
+// other platform the version lacks.
+func platformIn(cfg Config, idx Index, version string) (string, error) {
+	key, err := targetPlatformKey(cfg)
+	var unsupported *unsupportedArchError
+	if errors.As(err, &unsupported) {
+		return "", idx.noBuildError(version, key, unsupported.Error())
+	}
+	return key, err
+}
+
+// platformKeyFor translates a GOOS/GOARCH pair into an index key. Unknown
+// architectures are an error, with the raw GOARCH based key returned
+// alongside for messages.
+func platformKeyFor(goos, goarch string) (string, error) {
+	os, ok := zigOSes[goos]
+	if !ok {
+		os = goos
+	}
+	arch, ok := zigArchs[goarch]
+	if !ok {
+		return fmt.Sprintf("%s-%s", goarch, os), &kindError{kind: kindNotFound, err: &unsupportedArchError{goarch: goarch}}
+	}
+	return fmt.Sprintf("%s-%s", arch, os), nil
+}
@@ -0,0 +1,40 @@
+//go:build freebsd || netbsd
+
+package main
+
+import (
+	"flag"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestBSDHostPlatform(t *testing.T) {
+	key, err := hostPlatformKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(key, "-"+runtime.GOOS) {
+		t.Errorf("host platform %s does not name %s", key, runtime.GOOS)
+	}
+}
+
+func TestBSDDefaultDirs(t *testing.T) {
+	for _, name := range []string{"ZIG_BIN_DIR", "ZIG_LIB_DIR"} {
+		// Setenv restores the variable after the test
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	var cfg Config
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerFlags(fs, &cfg)
+	if cfg.BinDir != "/usr/local/bin" || cfg.LibDir != "/usr/local/lib" {
+		t.Errorf("default dirs are %s and %s, want /usr/local/bin and /usr/local/lib", cfg.BinDir, cfg.LibDir)
+	}
+
+	want := map[string]string{"freebsd": "/usr/local/share", "netbsd": "/usr/pkg/share"}[runtime.GOOS]
+	if got := systemSharePrefix(); got != want {
+		t.Errorf("systemSharePrefix() = %s, want %s", got, want)
+	}
+}
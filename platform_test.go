@@ -0,0 +1,185 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// recordedWindowsIndex has the Windows platforms of the 0.13.0 entry of
+// ziglang.org/download/index.json, every arch the index builds for.
+const recordedWindowsIndex = `{
+	"0.13.0": {
+		"date": "2024-06-07",
+		"x86_64-windows": {"tarball": "https://ziglang.org/download/0.13.0/zig-windows-x86_64-0.13.0.zip", "shasum": "d859994725ef9402381e557c60bb57497215682e355204d754ee3df75ee3c158", "size": "79163968"},
+		"aarch64-windows": {"tarball": "https://ziglang.org/download/0.13.0/zig-windows-aarch64-0.13.0.zip", "shasum": "95ff88427af7ba2b4f312f45d2377ce7a033e5e3c620c8caaa396a9aba20efda", "size": "75119033"},
+		"x86-windows": {"tarball": "https://ziglang.org/download/0.13.0/zig-windows-x86-0.13.0.zip", "shasum": "eb3d533c3cf868bff7e74455dc005d18fd836c42e50b27106b31e9fec6dffc4a", "size": "83274739"}
+	}
+}`
+
+func TestWindowsPlatforms(t *testing.T) {
+	index, err := parseIndex([]byte(recordedWindowsIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for goarch, want := range map[string]string{"amd64": "x86_64-windows", "arm64": "aarch64-windows", "386": "x86-windows"} {
+		key, _, err := detectPlatform(hostInfo{GOOS: "windows", GOARCH: goarch})
+		if err != nil || key != want {
+			t.Errorf("windows/%s maps to %s, %v; want %s", goarch, key, err, want)
+			continue
+		}
+		artifact, err := index.artifactFor(silentLogger{}, "0.13.0", key, "")
+		if err != nil {
+			t.Errorf("no artifact for %s: %v", key, err)
+		} else if !strings.HasSuffix(artifact.Tarball, ".zip") {
+			t.Errorf("%s picks %s, want the .zip", key, artifact.Tarball)
+		}
+		if got := binaryFile("zig", key); got != "zig.exe" {
+			t.Errorf("binaryFile on %s = %s, want zig.exe", key, got)
+		}
+	}
+}
+
+func TestBinaryFile(t *testing.T) {
+	tests := []struct{ name, key, want string }{
+		{"zig", "x86_64-linux", "zig"},
+		{"zig", "aarch64-macos", "zig"},
+		{"zig", "x86_64-windows", "zig.exe"},
+		{"zig.exe", "x86_64-windows", "zig.exe"},
+		{"zls", "aarch64-windows", "zls.exe"},
+	}
+	for _, tt := range tests {
+		if got := binaryFile(tt.name, tt.key); got != tt.want {
+			t.Errorf("binaryFile(%s, %s) = %s, want %s", tt.name, tt.key, got, tt.want)
+		}
+	}
+}
+
+// recordedPlatformKeys are the platform keys the upstream index has used
+// for its tarballs across the 0.11.0 to 0.14.0 releases.
+var recordedPlatformKeys = map[string]bool{
+	"x86_64-linux":      true,
+	"aarch64-linux":     true,
+	"x86-linux":         true,
+	"armv7a-linux":      true,
+	"riscv64-linux":     true,
+	"powerpc64le-linux": true,
+	"loongarch64-linux": true,
+	"s390x-linux":       true,
+	"x86_64-macos":      true,
+	"aarch64-macos":     true,
+	"x86_64-windows":    true,
+	"aarch64-windows":   true,
+	"x86-windows":       true,
+	"x86_64-freebsd":    true,
+}
+
+func TestPlatformKeyFor(t *testing.T) {
+	tests := []struct{ goos, goarch, want string }{
+		{"linux", "amd64", "x86_64-linux"},
+		{"linux", "arm64", "aarch64-linux"},
+		{"linux", "386", "x86-linux"},
+		{"darwin", "amd64", "x86_64-macos"},
+		{"darwin", "arm64", "aarch64-macos"},
+		{"windows", "amd64", "x86_64-windows"},
+		{"windows", "arm64", "aarch64-windows"},
+		{"windows", "386", "x86-windows"},
+		{"freebsd", "amd64", "x86_64-freebsd"},
+		{"android", "arm64", "aarch64-linux"},
+	}
+	for _, tt := range tests {
+		got, err := platformKeyFor(tt.goos, tt.goarch)
+		if err != nil || got != tt.want {
+			t.Errorf("platformKeyFor(%s, %s) = %s, %v; want %s", tt.goos, tt.goarch, got, err, tt.want)
+		}
+		if !recordedPlatformKeys[got] {
+			t.Errorf("%s/%s maps to %s, which the index has never used", tt.goos, tt.goarch, got)
+		}
+	}
+}
+
+func TestZigName(t *testing.T) {
+	for value, want := range map[string]string{"arm64": "aarch64", "aarch64": "aarch64", "amd64": "x86_64", "x86_64": "x86_64", "386": "x86"} {
+		if got := zigName(value, zigArchs); got != want {
+			t.Errorf("zigName(%s, zigArchs) = %s, want %s", value, got, want)
+		}
+	}
+	for value, want := range map[string]string{"darwin": "macos", "macos": "macos", "linux": "linux"} {
+		if got := zigName(value, zigOSes); got != want {
+			t.Errorf("zigName(%s, zigOSes) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestPlatformKeyForOtherArchitectures(t *testing.T) {
+	for goarch, want := range map[string]string{
+		"riscv64": "riscv64-linux",
+		"ppc64le": "powerpc64le-linux",
+		"s390x":   "s390x-linux",
+		"loong64": "loongarch64-linux",
+	} {
+		got, err := platformKeyFor("linux", goarch)
+		if err != nil || got != want {
+			t.Errorf("platformKeyFor(linux, %s) = %s, %v; want %s", goarch, got, err, want)
+		}
+		if !recordedPlatformKeys[got] {
+			t.Errorf("linux/%s maps to %s, which the index has never used", goarch, got)
+		}
+	}
+}
+
+func TestPlatformKeyForUnknownArchitecture(t *testing.T) {
+	for _, goarch := range []string{"mips", "mips64le", "ppc64", "wasm", "sparc64"} {
+		key, err := platformKeyFor("linux", goarch)
+		if err == nil {
+			t.Errorf("platformKeyFor(linux, %s) = %s without an error", goarch, key)
+			continue
+		}
+		if key != goarch+"-linux" {
+			t.Errorf("platformKeyFor(linux, %s) returned key %s, want the raw %s-linux", goarch, key, goarch)
+		}
+		if !strings.Contains(err.Error(), `GOARCH "`+goarch+`"`) || errorKindOf(err) != kindNotFound {
+			t.Errorf("platformKeyFor(linux, %s) error %q (kind %s) does not name the GOARCH", goarch, err, errorKindOf(err))
+		}
+	}
+}
+
+func TestPlatformKeyForBSDs(t *testing.T) {
+	for goos, want := range map[string]string{"freebsd": "x86_64-freebsd", "netbsd": "x86_64-netbsd"} {
+		if got, err := platformKeyFor(goos, "amd64"); err != nil || got != want {
+			t.Errorf("platformKeyFor(%s, amd64) = %s, %v; want %s", goos, got, err, want)
+		}
+	}
+}
+
+func TestRosettaModifier(t *testing.T) {
+	tests := []struct {
+		host hostInfo
+		want string
+		note bool
+	}{
+		{hostInfo{GOOS: "darwin", GOARCH: "amd64", Rosetta: true}, "aarch64-macos", true},
+		{hostInfo{GOOS: "darwin", GOARCH: "amd64"}, "x86_64-macos", false},
+		{hostInfo{GOOS: "darwin", GOARCH: "arm64"}, "aarch64-macos", false},
+		{hostInfo{GOOS: "linux", GOARCH: "amd64", Rosetta: true}, "x86_64-linux", false},
+	}
+	for _, tt := range tests {
+		key, notes, err := detectPlatform(tt.host)
+		if err != nil || key != tt.want {
+			t.Errorf("detectPlatform(%+v) = %s, %v; want %s", tt.host, key, err, tt.want)
+		}
+		if got := len(notes) == 1 && notes[0].Rule == "rosetta"; got != tt.note {
+			t.Errorf("detectPlatform(%+v) notes %+v, want a rosetta note: %v", tt.host, notes, tt.note)
+		}
+	}
+}
+
+func TestPreferHostArch(t *testing.T) {
+	process, err := processPlatformKey()
+	if err != nil {
+		t.Skip(err)
+	}
+	got, err := targetPlatformKey(Config{PreferHostArch: true, Logger: silentLogger{}})
+	if err != nil || got != process {
+		t.Errorf("--prefer-host-arch picks %s, %v; want the installer's own %s", got, err, process)
+	}
+}
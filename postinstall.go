@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// zigVersionOf runs "<bin> version" and returns its trimmed output.
+func zigVersionOf(bin string) (string, error) {
+	out, err := exec.Command(bin, "version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// postInstallChecks runs the freshly installed binary and warns about
+// anything that would keep the user from using it, or with --update-path
+// puts the bin directory on PATH. For a --root install the staged binary
+// is run, and the host's PATH is not checked since it says nothing about
+// the target system, nor for --ephemeral, which prints the PATH to use.
+func postInstallChecks(cfg Config, m Manifest) {
+	if m.Foreign {
+		cfg.log().Info("skipped running the installed binary: %s builds cannot run on this %s host", m.Platform, getPlatformKey())
+	} else {
+		checkInstalledBinary(cfg, m)
+	}
+
+	if cfg.Root != "" || cfg.Ephemeral {
+		return
+	}
+	checkWSLShadowing(cfg.log(), cfg.BinDir, cfg.BinName)
+	if onPath(cfg.BinDir) {
+		return
+	}
+	if !cfg.UpdatePath {
+		cfg.log().Warning("%s is not on your PATH; --update-path adds it to your shell profile", cfg.BinDir)
+	} else if err := updatePath(cfg.log(), cfg.BinDir); err != nil {
+		cfg.log().Warning("%s is not on your PATH and adding it failed: %v", cfg.BinDir, err)
+	}
+}
+
+// checkInstalledBinary runs the installed binary and compares the version
+// it reports with the manifest.
+func checkInstalledBinary(cfg Config, m Manifest) {
+	got, err := zigVersionOf(cfg.rooted(m.BinPath))
+	switch {
+	case err != nil:
+		cfg.log().Warning("installed binary failed to run: %v", err)
+		if hint := diagnoseRunFailure(cfg.rooted(m.BinPath), err); hint != "" {
+			cfg.log().Warning("%s", hint)
+		}
+	case got != m.Version:
+		cfg.log().Warning("installed binary reports version %s, expected %s", got, m.Version)
+	}
+}
+
+// onPath reports whether dir is one of the PATH entries.
+func onPath(dir string) bool {
+	want := filepath.Clean(dir)
+	for _, p := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(p) == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// prefetchResult is the outcome for one platform of --prefetch.
+type prefetchResult struct {
+	Platform string `json:"platform"`
+	OK       bool   `json:"ok"`
+	Status   string `json:"status"`
+	Version  string `json:"version,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// prefetch downloads and verifies the tarball of cfg.Version for every
+// platform in cfg.Prefetch into the cache directory, concurrently, without
+// installing anything. It fails if any platform fails. The first failure
+// stops the others unless --keep-going is set, which attempts them all.
+func prefetch(cfg Config) error {
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	if err := ensureDirectoryExists(cfg.CacheDir); err != nil {
+		return newError(kindFilesystem, "failed to create cache directory: %v", err)
+	}
+
+	if err := awaitIndex(cfg, client); err != nil {
+		return err
+	}
+	res, err := resolveIndexVersion(cfg, client)
+	if err != nil {
+		return err
+	}
+
+	// Every platform is looked up first, so without --keep-going one
+	// missing from the index fails the batch before anything is downloaded
+	results := make([]prefetchResult, len(cfg.Prefetch))
+	releases := make([]release, len(cfg.Prefetch))
+	resolved := true
+	for i, platform := range cfg.Prefetch {
+		results[i] = prefetchResult{Platform: platform, Status: "failed"}
+		if releases[i], err = res.releaseFor(cfg, client, platform); err != nil {
+			results[i].Error = err.Error()
+			resolved = false
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if !resolved && !cfg.KeepGoing {
+		cancel()
+	}
+	batch := withContext(client, ctx)
+	var wg sync.WaitGroup
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = prefetchPlatform(ctx, cfg, batch, releases[i], results[i])
+			if !results[i].OK && !cfg.KeepGoing {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed, skipped := 0, 0
+	for _, r := range results {
+		switch {
+		case r.Status == "skipped":
+			skipped++
+		case !r.OK:
+			failed++
+		}
+	}
+
+	if cfg.JSON {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			detail := r.Path
+			if !r.OK {
+				detail = r.Error
+			}
+			fmt.Printf("%-18s %-10s %s\n", r.Platform, r.Status, detail)
+		}
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("%d of %d platforms failed to prefetch, %d skipped (--keep-going attempts every platform)", failed, len(results), skipped)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d platforms failed to prefetch", failed, len(results))
+	}
+	cfg.log().Success("prefetched Zig %s for %d platforms into %s", res.version, len(results), cfg.CacheDir)
+	return nil
+}
+
+// prefetchPlatform downloads rel into the cache directory, filling in
+// result. It is skipped once ctx is canceled because another platform
+// failed.
+func prefetchPlatform(ctx context.Context, cfg Config, client *http.Client, rel release, result prefetchResult) prefetchResult {
+	platform := result.Platform
+	result.Version = rel.Version
+
+	dest := filepath.Join(cfg.CacheDir, path.Base(rel.Artifact.Tarball))
+	result.Path = dest
+	if verifyChecksum(dest, rel.Artifact.Shasum) == nil {
+		result.OK, result.Status = true, "cached"
+		return result
+	}
+
+	if err := checkDownloadSize(rel.Artifact, cfg.MaxDownloadSize); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if ctx.Err() != nil {
+		result.Status, result.Error = "skipped", "another platform failed"
+		return result
+	}
+	cfg.log().Step("downloading Zig %s for %s...", rel.Version, platform)
+	if err := fetchVerified(cfg.log(), client, rel.Artifact.Tarball, rel.Artifact.Shasum, dest, cfg.MaxDownloadSize); err != nil {
+		os.Remove(dest)
+		result.Error = err.Error()
+		if errors.Is(err, context.Canceled) {
+			result.Status, result.Error = "skipped", "another platform failed"
+		}
+		return result
+	}
+	result.OK, result.Status = true, "downloaded"
+	return result
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// platformOutput is the --json shape of --print-platform.
+type platformOutput struct {
+	GOOS      string         `json:"goos"`
+	GOARCH    string         `json:"goarch"`
+	Platform  string         `json:"platform"`
+	Modifiers []platformNote `json:"modifiers"`
+	// Target is the platform installed for when --target, --os or --arch
+	// make it differ from the host's.
+	Target   string `json:"target,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Artifact string `json:"artifact,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// printPlatform reports for --print-platform how the host maps to an
+// index key and whether the index has a build of the requested version
+// for it, meant to be pasted into bug reports. It exits with the code of
+// the lookup's error when there is none, after printing what it found.
+func printPlatform(cfg Config) error {
+	h := currentHost()
+	key, notes, keyErr := detectPlatform(h)
+	out := platformOutput{GOOS: h.GOOS, GOARCH: h.GOARCH, Platform: key, Modifiers: notes}
+	if out.Modifiers == nil {
+		out.Modifiers = []platformNote{}
+	}
+
+	err := keyErr
+	if err == nil {
+		err = lookupPlatformArtifact(cfg, &out)
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+
+	if cfg.JSON {
+		if err := printJSON(out); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println(out.Platform)
+		printField("go", out.GOOS+"/"+out.GOARCH)
+		if len(notes) == 0 {
+			printField("detected", "nothing special")
+		}
+		for _, n := range notes {
+			printField("detected", fmt.Sprintf("%s: %s", n.Rule, n.Note))
+		}
+		if out.Target != "" {
+			printField("target", out.Target)
+		}
+		printField("version", out.Version)
+		if out.Error != "" {
+			printField("artifact", "none: "+out.Error)
+		} else {
+			printField("artifact", out.Artifact)
+		}
+	}
+	if err != nil {
+		return exitCode(exitCodeFor(err))
+	}
+	return nil
+}
+
+// lookupPlatformArtifact fills in the target, version and artifact of out
+// the way an install would resolve them.
+func lookupPlatformArtifact(cfg Config, out *platformOutput) error {
+	target, err := targetPlatformKey(cfg)
+	if err != nil {
+		return err
+	}
+	if target != out.Platform {
+		out.Target = target
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	rel, err := resolveRelease(cfg, client)
+	if err != nil {
+		out.Version = cfg.Version
+		return err
+	}
+	out.Version = rel.Version
+	if rel.Platform != out.Platform {
+		out.Target = rel.Platform
+	}
+	out.Artifact = rel.Artifact.Tarball
+	return nil
+}
+
+// platformsOutput is the --json shape of --platforms.
+type platformsOutput struct {
+	Platform  string   `json:"platform"`
+	Version   string   `json:"version"`
+	Platforms []string `json:"platforms"`
+	Available bool     `json:"available"`
+}
+
+// printPlatforms lists for --platforms every platform key the index ships
+// the requested version for, marking the one this host computes (or
+// --target, --os and --arch ask for). It exits with the not found code
+// when that key is missing, after listing the others.
+func printPlatforms(cfg Config) error {
+	key, err := targetPlatformKey(cfg)
+	var unsupported *unsupportedArchError
+	if err != nil && !errors.As(err, &unsupported) {
+		return err
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	res, err := resolveIndexVersion(cfg, client)
+	if err != nil {
+		return err
+	}
+	if res.dev {
+		return newError(kindNotFound, "%s is not in the index, so the platforms it ships for are unknown", res.version)
+	}
+	entry := res.index[res.key]
+	key = pickARMVariant(cfg, entry, key)
+
+	out := platformsOutput{
+		Platform:  key,
+		Version:   res.version,
+		Platforms: entry.platforms(),
+		Available: entry.entryKey(key) != "",
+	}
+	if cfg.JSON {
+		if err := printJSON(out); err != nil {
+			return err
+		}
+	} else {
+		label := "this host"
+		if key != getPlatformKey() {
+			label = "requested"
+		}
+		cfg.log().Info("platforms of Zig %s, looking for %s (%s):", out.Version, key, label)
+		for _, p := range out.Platforms {
+			if p == entry.entryKey(key) {
+				fmt.Printf("%s  <- %s\n", p, label)
+				continue
+			}
+			fmt.Println(p)
+		}
+		if !out.Available {
+			cfg.log().Warning("%s is not among them", key)
+		}
+	}
+	if !out.Available {
+		return exitCode(exitNotFound)
+	}
+	return nil
+}
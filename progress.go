@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+)
+
+// ProgressFunc is called while long running stages make progress. stage
+// is "download" or "extract"; total is -1 when the size is unknown.
+type ProgressFunc func(stage string, current, total int64)
+
+// progressReader reports every read to a ProgressFunc.
+type progressReader struct {
+	r        io.Reader
+	stage    string
+	current  int64
+	total    int64
+	progress ProgressFunc
+}
+
+func newProgressReader(r io.Reader, stage string, total int64, progress ProgressFunc) io.Reader {
+	if progress == nil {
+		return r
+	}
+	progress(stage, 0, total)
+	return &progressReader{r: r, stage: stage, total: total, progress: progress}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.current += int64(n)
+	if n > 0 || err == io.EOF {
+		p.progress(p.stage, p.current, p.total)
+	}
+	return n, err
+}
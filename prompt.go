@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// confirmInstall asks before changing anything on interactive runs.
+// Replacing a different version or an install zig-installer did not make,
+// and installing into directories outside the user's home, such as
+// /usr/local, have to be confirmed explicitly; otherwise only a download
+// is asked about, defaulting to yes. --yes, --json, JSON logs, --quiet
+// and a non-terminal stdin all count as yes.
+func confirmInstall(cfg Config, rel release, download bool) bool {
+	if cfg.Yes || cfg.JSON || !isTerminal(os.Stdin) {
+		return true
+	}
+	if l, ok := cfg.log().(consoleLogger); ok && (l.json || l.level < levelNormal) {
+		return true
+	}
+	if old, ok := replacedInstall(cfg, rel); ok {
+		return confirm(fmt.Sprintf("replace zig %s with %s?", old, rel.Version), false)
+	}
+	if writesSystemDirs(cfg) {
+		binPath := filepath.Join(cfg.rooted(cfg.BinDir), binaryFile(cfg.BinName, rel.Platform))
+		libPath := filepath.Join(cfg.rooted(cfg.LibDir), cfg.BinName)
+		return confirm(fmt.Sprintf("Install Zig %s to %s and %s?", rel.Version, binPath, libPath), false)
+	}
+	if !download {
+		return true
+	}
+	size := "an unknown amount"
+	if rel.Artifact.Size > 0 {
+		size = formatBytes(rel.Artifact.Size)
+	}
+	return confirm(fmt.Sprintf("about to download %s (zig %s, %s) — continue?", size, rel.Version, rel.Platform), true)
+}
+
+// replacedInstall describes the install that installing rel would
+// replace, such as "0.12.1 (unmanaged)", when that is another version or
+// one without a manifest. The version comes from running the binary.
+func replacedInstall(cfg Config, rel release) (string, bool) {
+	if cfg.DestOnly || cfg.InstallTarballTo != "" {
+		return "", false
+	}
+	binPath := filepath.Join(cfg.rooted(cfg.BinDir), binaryFile(cfg.BinName, rel.Platform))
+	libPath := filepath.Join(cfg.rooted(cfg.LibDir), cfg.BinName)
+	_, binErr := os.Lstat(binPath)
+	_, libErr := os.Lstat(libPath)
+	if binErr != nil && libErr != nil {
+		return "", false
+	}
+
+	version, err := zigVersionOf(binPath)
+	if err != nil {
+		version = "of unknown version"
+	}
+	if _, err := readManifest(manifestPath(libPath)); err != nil {
+		return version + " (unmanaged)", true
+	}
+	return version, version != rel.Version
+}
+
+// writesSystemDirs reports whether the install writes to --bin-dir or
+// --lib-dir outside the user's home. Modes that leave both alone don't.
+func writesSystemDirs(cfg Config) bool {
+	if cfg.DestOnly || cfg.InstallTarballTo != "" {
+		return false
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return true
+	}
+	for _, dir := range []string{cfg.rooted(cfg.BinDir), cfg.rooted(cfg.LibDir)} {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return true
+		}
+		rel, err := filepath.Rel(home, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from
+// stdin. An empty answer picks def.
+func confirm(question string, def bool) bool {
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	fmt.Fprintf(os.Stderr, "%s %s ", question, choices)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
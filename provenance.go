@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// provenanceSchemaVersion is bumped whenever a field changes meaning, so
+// consumers can tell records apart.
+const provenanceSchemaVersion = 1
+
+// Provenance records what was installed, where it came from and what the
+// installed tree looked like afterwards.
+type Provenance struct {
+	SchemaVersion    int        `json:"schema_version"`
+	Name             string     `json:"name"`
+	Version          string     `json:"version"`
+	Platform         string     `json:"platform"`
+	HostPlatform     string     `json:"host_platform"`
+	TarballURL       string     `json:"tarball_url"`
+	IndexURL         string     `json:"index_url,omitempty"`
+	Lockfile         string     `json:"lockfile,omitempty"`
+	SHA256           string     `json:"sha256"`
+	Signature        string     `json:"signature"`
+	InstallerVersion string     `json:"installer_version"`
+	InstalledAt      time.Time  `json:"installed_at"`
+	Tree             treeDigest `json:"tree"`
+}
+
+// treeDigest summarizes an installed tree: the digest covers the path and
+// content of every file.
+type treeDigest struct {
+	Files  int    `json:"files"`
+	SHA256 string `json:"sha256"`
+}
+
+// installTreeDigest hashes the binary at binPath and every regular file
+// below libPath except the manifest.
+func installTreeDigest(binPath, libPath string) (treeDigest, error) {
+	type file struct{ name, path string }
+	files := []file{{"zig", binPath}}
+	libPath = resolveInstall(libPath)
+
+	// Installs under another --bin-name keep the lib tree in lib/
+	if info, err := os.Stat(filepath.Join(libPath, "lib")); err == nil && info.IsDir() {
+		libPath = filepath.Join(libPath, "lib")
+	}
+	err := filepath.WalkDir(libPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || d.Name() == manifestName {
+			return nil
+		}
+		rel, err := filepath.Rel(libPath, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, file{"lib/" + filepath.ToSlash(rel), path})
+		return nil
+	})
+	if err != nil {
+		return treeDigest{}, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+
+	tree := sha256.New()
+	for _, f := range files {
+		sum, err := fileSHA256(f.path)
+		if err != nil {
+			return treeDigest{}, err
+		}
+		fmt.Fprintf(tree, "%s  %s\n", sum, f.name)
+	}
+	return treeDigest{Files: len(files), SHA256: hex.EncodeToString(tree.Sum(nil))}, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeProvenance(path string, p Provenance) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAll(path, append(data, '\n'))
+}
+
+func readProvenance(path string) (Provenance, error) {
+	var p Provenance
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("%s: %v", path, err)
+	}
+	if p.SchemaVersion > provenanceSchemaVersion {
+		return p, fmt.Errorf("%s: schema version %d is newer than this installer supports (%d)", path, p.SchemaVersion, provenanceSchemaVersion)
+	}
+	return p, nil
+}
+
+// runVerify re-checks the installed tree against its provenance record,
+// taken from --provenance or the install's manifest.
+func runVerify(log *Logger, args []string) error {
+	var cfg Config
+	fs := newCommandFlags("verify", "verify [flags]", &cfg)
+	if _, err := parseCommand(log, fs, &cfg, args); err != nil {
+		return err
+	}
+
+	libPath := filepath.Join(cfg.rooted(cfg.LibDir), cfg.BinName)
+	m, err := readManifest(manifestPath(libPath))
+	if err != nil {
+		return fmt.Errorf("no managed install found in %s", cfg.LibDir)
+	}
+
+	var p Provenance
+	switch {
+	case cfg.Provenance != "":
+		if p, err = readProvenance(cfg.Provenance); err != nil {
+			return newError(kindFilesystem, "failed to read provenance: %v", err)
+		}
+	case m.Provenance != nil:
+		p = *m.Provenance
+	default:
+		return newError(kindNotFound, "the install in %s has no provenance record; pass --provenance", cfg.LibDir)
+	}
+
+	cfg.log().Step("verifying Zig %s in %s against its provenance...", p.Version, cfg.LibDir)
+	got, err := installTreeDigest(cfg.rooted(m.BinPath), libPath)
+	if err != nil {
+		return newError(kindFilesystem, "failed to hash the install: %v", err)
+	}
+	if p.Version != m.Version || got != p.Tree {
+		cfg.log().Error("MODIFIED: expected Zig %s with %d files (%s), found Zig %s with %d files (%s)",
+			p.Version, p.Tree.Files, p.Tree.SHA256, m.Version, got.Files, got.SHA256)
+		return exitCode(exitChecksum)
+	}
+	cfg.log().Success("INTACT: %d files match the provenance of Zig %s from %s", got.Files, p.Version, p.TarballURL)
+	return nil
+}
+
+// installProvenance builds the provenance record of a fresh install.
+func installProvenance(rel release, binPath, libPath string, installedAt time.Time) (*Provenance, error) {
+	tree, err := installTreeDigest(binPath, libPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Provenance{
+		SchemaVersion:    provenanceSchemaVersion,
+		Name:             "zig",
+		Version:          rel.Version,
+		Platform:         rel.Platform,
+		HostPlatform:     getPlatformKey(),
+		TarballURL:       rel.Artifact.Tarball,
+		IndexURL:         rel.IndexURL,
+		Lockfile:         rel.Lockfile,
+		SHA256:           rel.Artifact.Shasum,
+		Signature:        "not-verified",
+		InstallerVersion: installerVersion(),
+		InstalledAt:      installedAt,
+		Tree:             tree,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+)
+
+// resolveVersion maps the requested version onto a key of the index.
+// "stable" picks the newest tagged release and "latest" the newest release
+// the pre-release policy admits. Range expressions such as "0.13.x" pick
+// the newest matching release, and everything else (including "master")
+// has to name an index key directly.
+func resolveVersion(index Index, requested string, policy prereleasePolicy) (string, error) {
+	keys := index.Keys()
+
+	switch requested {
+	case "stable", "latest":
+		if requested == "stable" {
+			policy = prereleaseExclude
+		}
+		for _, v := range sortedVersions(keys) {
+			if policy.admits(v, false) {
+				return v.String(), nil
+			}
+		}
+		return "", newError(kindNotFound, "no matching release found in index for %s", requested)
+	}
+
+	if _, ok := index[requested]; !ok && isVersionRange(requested) {
+		r, err := parseVersionRange(requested)
+		if err != nil {
+			return "", newError(kindUsage, "%v", err)
+		}
+		candidates := sortedVersions(keys)
+		for _, v := range candidates {
+			if r.matches(v, policy) {
+				return v.String(), nil
+			}
+		}
+		considered := make([]string, len(candidates))
+		for i, v := range candidates {
+			considered[i] = v.String()
+		}
+		return "", newError(kindNotFound, "no version in index satisfies %q (considered: %s)", requested, strings.Join(considered, ", "))
+	}
+
+	if _, ok := index[requested]; !ok {
+		return "", newError(kindNotFound, "version %s not found in index; %s", requested, notFoundHint(index, requested))
+	}
+	return requested, nil
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// zigVersion is a parsed Zig version string such as 0.13.0 or
+// 0.14.0-dev.2345+abcdef. Build metadata after '+' is kept but never
+// used for ordering.
+type zigVersion struct {
+	Major int
+	Minor int
+	Patch int
+	Pre   string
+	Build string
+}
+
+func parseVersion(s string) (zigVersion, error) {
+	var v zigVersion
+	rest := s
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		v.Build = rest[i+1:]
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		v.Pre = rest[i+1:]
+		rest = rest[:i]
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+func (v zigVersion) isPrerelease() bool {
+	return v.Pre != ""
+}
+
+func (v zigVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// compareVersions returns -1, 0 or 1 depending on whether a sorts before,
+// equal to, or after b.
+func compareVersions(a, b zigVersion) int {
+	if c := compareInts(a.Major, b.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(a.Minor, b.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(a.Patch, b.Patch); c != 0 {
+		return c
+	}
+	switch {
+	case a.Pre == b.Pre:
+		return 0
+	case a.Pre == "":
+		return 1
+	case b.Pre == "":
+		return -1
+	}
+	return comparePrerelease(a.Pre, b.Pre)
+}
+
+// comparePrerelease orders dot separated pre-release identifiers so that
+// 0.14.0-dev.99 sorts before 0.14.0-dev.1000.
+func comparePrerelease(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareInts(an, bn)
+		case aErr == nil:
+			// Numeric identifiers sort before alphanumeric ones
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(as), len(bs))
+}
+
+// isNewerVersion reports whether candidate is newer than installed. Strings
+// that don't parse as versions are only considered newer if they differ.
+func isNewerVersion(candidate, installed string) bool {
+	c, cErr := parseVersion(candidate)
+	i, iErr := parseVersion(installed)
+	if cErr != nil || iErr != nil {
+		return candidate != installed
+	}
+	return compareVersions(c, i) > 0
+}
+
+// sameCore reports whether a and b share major, minor and patch.
+func sameCore(a, b zigVersion) bool {
+	return a.Major == b.Major && a.Minor == b.Minor && a.Patch == b.Patch
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// sortedVersions parses every key that looks like a version and returns
+// them newest first. Keys such as "master" are skipped.
+func sortedVersions(keys []string) []zigVersion {
+	var versions []zigVersion
+	for _, k := range keys {
+		v, err := parseVersion(k)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) > 0
+	})
+	return versions
+}
+
+// versionConstraint is a single comparison such as ">=0.11.0".
+type versionConstraint struct {
+	op string
+	v  zigVersion
+}
+
+// versionRange is a set of constraints that must all hold, e.g. the
+// expression ">=0.11 <0.14".
+type versionRange struct {
+	expr        string
+	constraints []versionConstraint
+	// prerelease is set when the expression itself names a pre-release,
+	// otherwise dev builds never satisfy the range.
+	prerelease bool
+}
+
+// isVersionRange reports whether s should be treated as a constraint
+// expression rather than a literal index key.
+func isVersionRange(s string) bool {
+	if strings.ContainsAny(s, "<>=~^* ") {
+		return true
+	}
+	for _, p := range strings.Split(s, ".") {
+		if p == "x" || p == "X" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVersionRange understands the usual npm style forms: "0.13.x",
+// "0.13.*", "~0.12", "^0.12.1", "=0.13.0" and space separated comparisons
+// like ">=0.11 <0.14".
+func parseVersionRange(expr string) (versionRange, error) {
+	r := versionRange{expr: expr}
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return r, fmt.Errorf("empty version range")
+	}
+
+	for _, f := range fields {
+		op := ""
+		for _, candidate := range []string{">=", "<=", ">", "<", "=", "~", "^"} {
+			if strings.HasPrefix(f, candidate) {
+				op = candidate
+				break
+			}
+		}
+		v, precision, err := parsePartialVersion(strings.TrimPrefix(f, op))
+		if err != nil {
+			return r, fmt.Errorf("invalid version range %q: %v", expr, err)
+		}
+		if v.isPrerelease() {
+			r.prerelease = true
+		}
+
+		switch op {
+		case "~":
+			r.add(">=", v)
+			if precision == 1 {
+				r.add("<", zigVersion{Major: v.Major + 1})
+			} else {
+				r.add("<", zigVersion{Major: v.Major, Minor: v.Minor + 1})
+			}
+		case "^":
+			r.add(">=", v)
+			switch {
+			case v.Major > 0 || precision == 1:
+				r.add("<", zigVersion{Major: v.Major + 1})
+			case v.Minor > 0 || precision == 2:
+				r.add("<", zigVersion{Minor: v.Minor + 1})
+			default:
+				r.add("<", zigVersion{Patch: v.Patch + 1})
+			}
+		case "", "=":
+			if precision == 3 {
+				r.add("=", v)
+				break
+			}
+			// A partial version such as 0.13.x covers the whole series.
+			r.add(">=", v)
+			if precision == 1 {
+				r.add("<", zigVersion{Major: v.Major + 1})
+			} else {
+				r.add("<", zigVersion{Major: v.Major, Minor: v.Minor + 1})
+			}
+		case "<=", ">":
+			// "<=0.13" means anything in the 0.13 series.
+			if precision < 3 {
+				v = bumpPartial(v, precision)
+				if op == "<=" {
+					op = "<"
+				} else {
+					op = ">="
+				}
+			}
+			r.add(op, v)
+		default:
+			r.add(op, v)
+		}
+	}
+	return r, nil
+}
+
+func (r *versionRange) add(op string, v zigVersion) {
+	r.constraints = append(r.constraints, versionConstraint{op: op, v: v})
+}
+
+// parsePartialVersion accepts "0", "0.13", "0.13.x" and full versions and
+// reports how many components were given explicitly.
+func parsePartialVersion(s string) (zigVersion, int, error) {
+	if v, err := parseVersion(s); err == nil {
+		return v, 3, nil
+	}
+	var v zigVersion
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return v, 0, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	precision := 0
+	for i, p := range parts {
+		if p == "x" || p == "X" || p == "*" {
+			break
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || precision != i {
+			return v, 0, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+		precision++
+	}
+	if precision == 0 {
+		return v, 0, fmt.Errorf("invalid version %q", s)
+	}
+	return v, precision, nil
+}
+
+func bumpPartial(v zigVersion, precision int) zigVersion {
+	if precision == 1 {
+		return zigVersion{Major: v.Major + 1}
+	}
+	return zigVersion{Major: v.Major, Minor: v.Minor + 1}
+}
+
+// prereleasePolicy decides whether dev builds such as 0.14.0-dev.1+abc
+// take part in resolving "latest" and ranges.
+type prereleasePolicy int
+
+const (
+	// prereleaseExclude only lets ranges that name a pre-release match one.
+	prereleaseExclude prereleasePolicy = iota
+	// prereleaseAllow treats pre-releases like any other version.
+	prereleaseAllow
+	// prereleaseOnly restricts resolution to pre-releases.
+	prereleaseOnly
+)
+
+// admits reports whether the policy lets v be picked. named is set when
+// the request itself names a pre-release.
+func (p prereleasePolicy) admits(v zigVersion, named bool) bool {
+	switch p {
+	case prereleaseAllow:
+		return true
+	case prereleaseOnly:
+		return v.isPrerelease()
+	}
+	return !v.isPrerelease() || named
+}
+
+// matches reports whether v satisfies every constraint of the range and
+// the pre-release policy.
+func (r versionRange) matches(v zigVersion, policy prereleasePolicy) bool {
+	if !policy.admits(v, r.prerelease) {
+		return false
+	}
+	for _, c := range r.constraints {
+		cmp := compareVersions(v, c.v)
+		var ok bool
+		switch c.op {
+		case "=":
+			ok = cmp == 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			// Like npm's "<x.y.z-0": pre-releases of the bound itself
+			// don't sneak in below it, so 0.13.x never admits 0.14.0-dev
+			ok = cmp < 0 && !(v.isPrerelease() && c.v.Pre == "" && sameCore(v, c.v))
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (r versionRange) String() string {
+	return r.expr
+}
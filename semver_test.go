@@ -0,0 +1,173 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, err := parseVersion("0.14.0-dev.2345+abcdef012")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (zigVersion{Minor: 14, Pre: "dev.2345", Build: "abcdef012"}); v != want {
+		t.Errorf("parsed %+v, want %+v", v, want)
+	}
+	if v.String() != "0.14.0-dev.2345+abcdef012" {
+		t.Errorf("String() = %s", v)
+	}
+	for _, s := range []string{"", "0.13", "0.13.0.1", "v0.13.0", "0.-1.0", "master", "0.13.x"} {
+		if _, err := parseVersion(s); err == nil {
+			t.Errorf("parseVersion(%q) succeeded", s)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	// Ascending
+	ordered := []string{
+		"0.9.1",
+		"0.10.0",
+		"0.11.0-dev.1",
+		"0.11.0-dev.2",
+		"0.11.0-dev.10",
+		"0.11.0-dev.10.1",
+		"0.11.0-dev.alpha",
+		"0.11.0-rc.1",
+		"0.11.0",
+		"0.11.1",
+		"1.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := parseVersion(ordered[i])
+			b, _ := parseVersion(ordered[j])
+			if got, want := compareVersions(a, b), compareInts(i, j); got != want {
+				t.Errorf("compareVersions(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestCompareVersionsIgnoresBuild(t *testing.T) {
+	a, _ := parseVersion("0.14.0-dev.2345+abcdef012")
+	b, _ := parseVersion("0.14.0-dev.2345+fedcba987")
+	if c := compareVersions(a, b); c != 0 {
+		t.Errorf("build metadata changed the order: %d", c)
+	}
+	if isNewerVersion("0.14.0-dev.2345+fedcba987", "0.14.0-dev.2345+abcdef012") {
+		t.Error("a different commit hash of the same dev build counts as newer")
+	}
+}
+
+func TestSortedVersions(t *testing.T) {
+	got := sortedVersions([]string{"0.12.0", "master", "0.14.0-dev.99+a", "0.14.0-dev.1000+b", "0.13.0"})
+	want := []string{"0.14.0-dev.1000+b", "0.14.0-dev.99+a", "0.13.0", "0.12.0"}
+	if len(got) != len(want) {
+		t.Fatalf("sorted %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("sorted[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseVersionRange(t *testing.T) {
+	tests := []struct {
+		expr    string
+		matches []string
+		misses  []string
+	}{
+		{"0.13.x", []string{"0.13.0", "0.13.5"}, []string{"0.12.1", "0.14.0", "0.14.0-dev.1+a"}},
+		{"0.13.*", []string{"0.13.0"}, []string{"0.14.0"}},
+		{"~0.12", []string{"0.12.0", "0.12.1"}, []string{"0.11.0", "0.13.0"}},
+		{"~0.12.1", []string{"0.12.1", "0.12.9"}, []string{"0.12.0", "0.13.0"}},
+		{"^0.12.1", []string{"0.12.1", "0.12.4"}, []string{"0.12.0", "0.13.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^1.2", []string{"1.2.0", "1.9.0"}, []string{"1.1.0", "2.0.0"}},
+		{"=0.13.0", []string{"0.13.0"}, []string{"0.13.1"}},
+		{"0.13.0", []string{"0.13.0"}, []string{"0.13.1"}},
+		{">=0.11 <0.14", []string{"0.11.0", "0.13.0"}, []string{"0.10.1", "0.14.0", "0.14.0-dev.5+a"}},
+		{"<=0.12", []string{"0.12.1", "0.9.0"}, []string{"0.13.0"}},
+		{">0.12", []string{"0.13.0"}, []string{"0.12.1"}},
+		{">0.12.0", []string{"0.12.1"}, []string{"0.12.0"}},
+		{">=0.14.0-dev.100", []string{"0.14.0-dev.100+a", "0.14.0-dev.1000+b", "0.14.0"}, []string{"0.14.0-dev.99+a", "0.13.0"}},
+	}
+	for _, tt := range tests {
+		r, err := parseVersionRange(tt.expr)
+		if err != nil {
+			t.Errorf("parseVersionRange(%q): %v", tt.expr, err)
+			continue
+		}
+		for _, s := range tt.matches {
+			v, _ := parseVersion(s)
+			if !r.matches(v, prereleaseExclude) {
+				t.Errorf("%q does not match %s", tt.expr, s)
+			}
+		}
+		for _, s := range tt.misses {
+			v, _ := parseVersion(s)
+			if r.matches(v, prereleaseExclude) {
+				t.Errorf("%q matches %s", tt.expr, s)
+			}
+		}
+	}
+	for _, expr := range []string{"", ">=", "~x", "0.a", "1.2.3.4"} {
+		if _, err := parseVersionRange(expr); err == nil {
+			t.Errorf("parseVersionRange(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestIsVersionRange(t *testing.T) {
+	for s, want := range map[string]bool{
+		"0.13.x": true, "0.13.*": true, ">=0.11": true, "~0.12": true, "^0.12": true, ">=0.11 <0.14": true,
+		"0.13.0": false, "master": false, "0.13": false, "0.14.0-dev.1+abc": false,
+	} {
+		if got := isVersionRange(s); got != want {
+			t.Errorf("isVersionRange(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+// prereleaseIndex has tagged releases and dev builds of the next one.
+const prereleaseIndex = `{
+	"master": {"version": "0.14.0-dev.2345+abcdef012"},
+	"0.14.0-dev.2345+abcdef012": {},
+	"0.14.0-dev.99+0123456": {},
+	"0.13.0": {},
+	"0.12.1": {}
+}`
+
+func TestResolveVersionPrereleasePolicy(t *testing.T) {
+	index, err := parseIndex([]byte(prereleaseIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		requested string
+		policy    prereleasePolicy
+		want      string
+	}{
+		{"stable", prereleaseExclude, "0.13.0"},
+		{"stable", prereleaseAllow, "0.13.0"},
+		{"stable", prereleaseOnly, "0.13.0"},
+		{"latest", prereleaseExclude, "0.13.0"},
+		{"latest", prereleaseAllow, "0.14.0-dev.2345+abcdef012"},
+		{"latest", prereleaseOnly, "0.14.0-dev.2345+abcdef012"},
+		{">=0.12", prereleaseExclude, "0.13.0"},
+		{">=0.12", prereleaseAllow, "0.14.0-dev.2345+abcdef012"},
+		{"<0.14.0-dev.1000", prereleaseOnly, "0.14.0-dev.99+0123456"},
+		{"0.14.0-dev.99+0123456", prereleaseExclude, "0.14.0-dev.99+0123456"},
+		{"master", prereleaseExclude, "master"},
+	}
+	for _, tt := range tests {
+		got, err := resolveVersion(index, tt.requested, tt.policy)
+		if err != nil || got != tt.want {
+			t.Errorf("resolveVersion(%q, %d) = %s, %v; want %s", tt.requested, tt.policy, got, err, tt.want)
+		}
+	}
+	if _, err := resolveVersion(index, "0.12.x", prereleaseOnly); errorKindOf(err) != kindNotFound {
+		t.Errorf("--only-prerelease with a stable range: %v, want not found", err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// cacheServer proxies the upstream index and serves verified tarballs out
+// of a local cache directory so a fleet only hits ziglang.org once.
+type cacheServer struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	sources   string // index URLs after redirects, for tarball host checks
+	artifacts map[string]Artifact
+	fetching  map[string]*sync.Mutex
+	verified  map[string]bool
+}
+
+func serve(cfg Config, client *http.Client) error {
+	if err := ensureDirectoryExists(cfg.CacheDir); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	s := &cacheServer{
+		cfg:       cfg,
+		client:    client,
+		sources:   cfg.IndexURL,
+		artifacts: make(map[string]Artifact),
+		fetching:  make(map[string]*sync.Mutex),
+		verified:  make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.json", s.handleIndex)
+	mux.HandleFunc("/", s.handleTarball)
+
+	cfg.log().Info("serving %s on %s (cache: %s)", cfg.IndexURL, cfg.Serve, cfg.CacheDir)
+	return http.ListenAndServe(cfg.Serve, mux)
+}
+
+// handleIndex fetches the upstream index and rewrites every tarball URL
+// to point back at this server.
+func (s *cacheServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	index, sources, err := fetchIndexSources(s.cfg, s.client, s.cfg.IndexURL)
+	if err != nil {
+		s.cfg.log().Error("%v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	base := fmt.Sprintf("%s://%s", scheme, r.Host)
+
+	s.mu.Lock()
+	s.sources = sources
+	for version, entry := range index {
+		for platform, a := range entry.Artifacts {
+			name := path.Base(a.Tarball)
+			s.artifacts[name] = a
+			entry.Artifacts[platform] = Artifact{Tarball: base + "/" + name, Shasum: a.Shasum, Size: a.Size}
+		}
+		for _, variants := range entry.Variants {
+			for variant, a := range variants {
+				name := path.Base(a.Tarball)
+				s.artifacts[name] = a
+				variants[variant] = Artifact{Tarball: base + "/" + name, Shasum: a.Shasum, Size: a.Size}
+			}
+		}
+		index[version] = entry
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(index); err != nil {
+		s.cfg.log().Error("failed to write index: %v", err)
+	}
+}
+
+// handleTarball serves a cached tarball, downloading and verifying it
+// first if this is the first request for it.
+func (s *cacheServer) handleTarball(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name == "" || name != path.Base(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	a, ok := s.artifacts[name]
+	lock, busy := s.fetching[name]
+	if !busy {
+		lock = &sync.Mutex{}
+		s.fetching[name] = lock
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		// Clients using --mirror may ask for a tarball before the index.
+		if index, sources, err := fetchIndexSources(s.cfg, s.client, s.cfg.IndexURL); err == nil {
+			s.mu.Lock()
+			s.sources = sources
+			for _, entry := range index {
+				for _, artifact := range entry.Artifacts {
+					s.artifacts[path.Base(artifact.Tarball)] = artifact
+				}
+				for _, variants := range entry.Variants {
+					for _, artifact := range variants {
+						s.artifacts[path.Base(artifact.Tarball)] = artifact
+					}
+				}
+			}
+			a, ok = s.artifacts[name]
+			s.mu.Unlock()
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	dest := filepath.Join(s.cfg.CacheDir, name)
+
+	lock.Lock()
+	s.mu.Lock()
+	verified := s.verified[name]
+	sources := s.sources
+	s.mu.Unlock()
+	if !verified && verifyChecksum(dest, a.Shasum) != nil {
+		if err := checkDownloadSize(a, s.cfg.MaxDownloadSize); err != nil {
+			lock.Unlock()
+			s.cfg.log().Error("%v", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if err := validateTarballURL(a.Tarball, sources, mirrorHosts(s.cfg.Mirrors), s.cfg.AllowForeignHost); err != nil {
+			lock.Unlock()
+			s.cfg.log().Error("%v", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		s.cfg.log().Step("caching %s...", name)
+		if err := fetchVerified(s.cfg.log(), s.client, a.Tarball, a.Shasum, dest, s.cfg.MaxDownloadSize); err != nil {
+			lock.Unlock()
+			s.cfg.log().Error("failed to cache %s: %v", name, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		s.cfg.log().Success("cached %s", name)
+	}
+	s.mu.Lock()
+	s.verified[name] = true
+	s.mu.Unlock()
+	lock.Unlock()
+
+	http.ServeFile(w, r, dest)
+}
+
+// fetchVerified downloads url next to dest and only moves it into place
+// once the checksum matches, so dest never holds an unverified file.
+func fetchVerified(log Logger, client *http.Client, url, shasum, dest string, maxSize int64) error {
+	tmp := dest + ".part"
+	defer os.Remove(tmp)
+
+	if err := downloadFile(log, client, url, tmp, maxSize, nil); err != nil {
+		return err
+	}
+	if err := verifyChecksum(tmp, shasum); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshSource is a file reachable over SSH, from an sftp:// URL or an
+// scp-style user@host:path. It is read over SFTP, authenticating with
+// the SSH agent or the default keys in ~/.ssh.
+type sshSource struct {
+	user string
+	host string
+	port string
+	path string
+}
+
+// scpStyle matches user@host:path and host:path, but not URLs or Windows
+// drive letters.
+var scpStyle = regexp.MustCompile(`^(?:([^@/:\s]+)@)?([^@/:\s\[\]]{2,}|\[[0-9a-fA-F:]+\]):([^/].*|/.*)?$`)
+
+// defaultSSHKeys are the keys in ~/.ssh tried after the agent's, in the
+// order ssh tries them.
+var defaultSSHKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// parseSSHSource recognizes the URLs fetched over SSH.
+func parseSSHSource(s string) (sshSource, bool) {
+	if strings.HasPrefix(s, "sftp://") || strings.HasPrefix(s, "scp://") {
+		u, err := url.Parse(s)
+		if err != nil || u.Hostname() == "" {
+			return sshSource{}, false
+		}
+		return sshSource{user: u.User.Username(), host: u.Hostname(), port: u.Port(), path: u.Path}, true
+	}
+	if strings.Contains(s, "://") {
+		return sshSource{}, false
+	}
+	m := scpStyle.FindStringSubmatch(s)
+	if m == nil {
+		return sshSource{}, false
+	}
+	return sshSource{user: m[1], host: strings.Trim(m[2], "[]"), path: m[3]}, true
+}
+
+// target renders the source the way scp takes it.
+func (s sshSource) target() string {
+	host := s.host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if s.user != "" {
+		host = s.user + "@" + host
+	}
+	return host + ":" + s.path
+}
+
+// addr is the host and port to connect to.
+func (s sshSource) addr() string {
+	port := s.port
+	if port == "" {
+		port = "22"
+	}
+	return net.JoinHostPort(s.host, port)
+}
+
+// remotePath is the path to open on the server. A relative path is
+// relative to the login directory, which is where SFTP servers start.
+func (s sshSource) remotePath() string {
+	if s.path == "" {
+		return "."
+	}
+	return s.path
+}
+
+// sshUser is the login name for src, defaulting to the local user the way
+// ssh does.
+func sshUser(src sshSource) string {
+	if src.user != "" {
+		return src.user
+	}
+	if u, err := user.Current(); err == nil {
+		// DOMAIN\name on Windows
+		return u.Username[strings.LastIndex(u.Username, `\`)+1:]
+	}
+	return os.Getenv("USER")
+}
+
+// sshSigners returns the keys of the SSH agent followed by the default
+// keys in ~/.ssh. Keys with a passphrase are left to the agent.
+func sshSigners(log Logger) []ssh.Signer {
+	var signers []ssh.Signer
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err != nil {
+			log.Debug("ssh agent at %s: %v", sock, err)
+		} else if keys, err := agent.NewClient(conn).Signers(); err != nil {
+			log.Debug("ssh agent at %s: %v", sock, err)
+		} else {
+			signers = append(signers, keys...)
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return signers
+	}
+	for _, name := range defaultSSHKeys {
+		path := filepath.Join(home, ".ssh", name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		key, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				log.Debug("skipping %s, which has a passphrase; add it to the ssh agent", path)
+			} else {
+				log.Debug("skipping %s: %v", path, err)
+			}
+			continue
+		}
+		signers = append(signers, key)
+	}
+	return signers
+}
+
+// knownHostsFiles returns the known_hosts files ssh would check.
+func knownHostsFiles() []string {
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".ssh", "known_hosts"))
+	}
+	files = append(files, "/etc/ssh/ssh_known_hosts")
+	var found []string
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// probeKey is a host key no known_hosts line matches, used to learn
+// which key types are on record for a host.
+type probeKey struct{}
+
+func (probeKey) Type() string                        { return "probe" }
+func (probeKey) Marshal() []byte                     { return []byte("probe") }
+func (probeKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key") }
+
+// hostKeyAlgorithms returns the host key algorithms to ask for, so the
+// server offers a key of a type known_hosts has for addr rather than its
+// preferred one.
+func hostKeyAlgorithms(check ssh.HostKeyCallback, addr string) []string {
+	var keyErr *knownhosts.KeyError
+	if !errors.As(check(addr, &net.TCPAddr{}, probeKey{}), &keyErr) {
+		return nil
+	}
+	var algos []string
+	for _, k := range keyErr.Want {
+		if k.Key.Type() == ssh.KeyAlgoRSA {
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algos = append(algos, k.Key.Type())
+	}
+	return algos
+}
+
+// dialSSH connects and authenticates to the host of src. The host key has
+// to be in known_hosts, as ssh would ask otherwise and nobody is there to
+// answer.
+func dialSSH(ctx context.Context, log Logger, src sshSource) (*ssh.Client, error) {
+	files := knownHostsFiles()
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no known_hosts file to check the host key of %s against; connect once with ssh to add it", src.host)
+	}
+	check, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, err
+	}
+	signers := sshSigners(log)
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no SSH key for %s: start an ssh agent or create ~/.ssh/%s", src.host, defaultSSHKeys[0])
+	}
+	addr := src.addr()
+	config := &ssh.ClientConfig{
+		User:              sshUser(src),
+		Auth:              []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback:   check,
+		HostKeyAlgorithms: hostKeyAlgorithms(check, addr),
+	}
+	log.Debug("connecting to %s as %s", addr, config.User)
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// The handshake gives up with the context
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return nil, fmt.Errorf("%s is not in known_hosts; connect once with ssh to add it", src.host)
+			}
+			return nil, fmt.Errorf("the host key of %s does not match known_hosts", src.host)
+		}
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// errTooLarge is returned by fetch for a file over its size cap.
+var errTooLarge = errors.New("file too large")
+
+// cappedWriter reports what is written to progress and fails with
+// errTooLarge once more than max bytes (0 for no limit) came through.
+type cappedWriter struct {
+	w        io.Writer
+	n        int64
+	max      int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (c *cappedWriter) Write(b []byte) (int, error) {
+	if c.max > 0 && c.n+int64(len(b)) > c.max {
+		c.n += int64(len(b))
+		return 0, errTooLarge
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	if c.progress != nil {
+		c.progress("download", c.n, c.total)
+	}
+	return n, err
+}
+
+// fetch copies the source to dest over SFTP and returns its size. A file
+// over maxSize (0 for no limit) fails with errTooLarge and the size seen
+// so far, before anything is read when the server reports the size.
+func (s sshSource) fetch(ctx context.Context, log Logger, dest string, maxSize int64, progress ProgressFunc) (int64, error) {
+	client, err := dialSSH(ctx, log, s)
+	if err != nil {
+		return 0, fmt.Errorf("ssh %s: %w", s.target(), err)
+	}
+	defer client.Close()
+	// Closing the client ends a transfer the context gave up on
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		return 0, fmt.Errorf("ssh %s: %w", s.target(), err)
+	}
+	defer sc.Close()
+	f, err := sc.Open(s.remotePath())
+	if err != nil {
+		return 0, fmt.Errorf("sftp %s: %w", s.target(), err)
+	}
+	defer f.Close()
+	total := int64(-1)
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		total = info.Size()
+	}
+	if maxSize > 0 && total > maxSize {
+		return total, errTooLarge
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	w := &cappedWriter{w: out, max: maxSize, total: total, progress: progress}
+	if progress != nil {
+		progress("download", 0, total)
+	}
+	if _, err := f.WriteTo(w); err != nil {
+		if errors.Is(err, errTooLarge) {
+			return w.n, errTooLarge
+		}
+		return w.n, fmt.Errorf("sftp %s: %w", s.target(), err)
+	}
+	return w.n, out.Close()
+}
+
+// downloadSSH is downloadFile for a source reachable over SSH.
+func downloadSSH(log Logger, src sshSource, dest string, maxSize int64, progress ProgressFunc) error {
+	n, err := src.fetch(context.Background(), log, dest, maxSize, progress)
+	if errors.Is(err, errTooLarge) {
+		os.Remove(dest)
+		return fmt.Errorf("%s is %s, more than --max-download-size %s", src.target(), formatBytes(n), formatBytes(maxSize))
+	}
+	return err
+}
+
+// fetchIndexSSH is fetchIndexData for an index reachable over SSH.
+func fetchIndexSSH(log Logger, url string, src sshSource, timeout time.Duration) (indexResponse, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	tmp, err := os.CreateTemp("", "zig-index-*.json")
+	if err != nil {
+		return indexResponse{}, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	n, err := src.fetch(ctx, log, tmp.Name(), maxIndexSize, nil)
+	if errors.Is(err, errTooLarge) {
+		return indexResponse{}, fmt.Errorf("index is %s, refusing to read more than %s", formatBytes(n), formatBytes(maxIndexSize))
+	}
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return indexResponse{}, newError(kindNetwork, "index request to %s timed out after %s; raise --index-timeout on slow links", src.target(), timeout)
+		}
+		return indexResponse{}, newError(kindNetwork, "failed to fetch index: %v", err)
+	}
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return indexResponse{}, err
+	}
+	return indexResponse{Data: data, URL: url}, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestParseSSHSource(t *testing.T) {
+	tests := []struct {
+		in   string
+		want sshSource
+		ok   bool
+	}{
+		{"sftp://zig@artifacts.lan/srv/zig/index.json", sshSource{user: "zig", host: "artifacts.lan", path: "/srv/zig/index.json"}, true},
+		{"sftp://mirror:2222/srv/zig", sshSource{host: "mirror", port: "2222", path: "/srv/zig"}, true},
+		{"zig@artifacts.lan:/srv/zig", sshSource{user: "zig", host: "artifacts.lan", path: "/srv/zig"}, true},
+		{"mirror:builds/zig.tar.xz", sshSource{host: "mirror", path: "builds/zig.tar.xz"}, true},
+		{"[fd00::1]:/srv/zig", sshSource{host: "fd00::1", path: "/srv/zig"}, true},
+		{"https://ziglang.org/download/index.json", sshSource{}, false},
+		{`C:\zig\index.json`, sshSource{}, false},
+		{"sftp:///srv/zig", sshSource{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSSHSource(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseSSHSource(%q) = %+v, %v; want %+v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// serveSSH serves the local filesystem read-only over SFTP on a local
+// port and returns its address. HOME is pointed at a directory holding a
+// client key and a known_hosts entry for the server unless unknownHost is
+// set.
+func serveSSH(t *testing.T, unknownHost bool) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the served paths are Unix paths")
+	}
+	hostPub, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	clientPub, clientPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nc := range chans {
+					ch, reqs, err := nc.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range reqs {
+							req.Reply(req.Type == "subsystem", nil)
+							if req.Type == "subsystem" {
+								if srv, err := sftp.NewServer(ch, sftp.ReadOnly()); err == nil {
+									srv.Serve()
+								}
+								ch.Close()
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	sshDir := filepath.Join(home, ".ssh")
+	os.Mkdir(sshDir, 0700)
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "id_ed25519"), pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	hostKey, _ := ssh.NewPublicKey(hostPub)
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	if unknownHost {
+		line = "# empty"
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "known_hosts"), []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func TestDownloadSSH(t *testing.T) {
+	tarball := strings.Repeat("zig tarball\n", 20000)
+	addr := serveSSH(t, false)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "srv", "zig.tar.xz")
+	fakeBinary(t, src, tarball)
+
+	var seen int64
+	dest := filepath.Join(dir, "zig.tar.xz")
+	progress := func(stage string, current, total int64) { seen = current }
+	if err := downloadFile(silentLogger{}, nil, "sftp://zig@"+addr+filepath.ToSlash(src), dest, 0, progress); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(dest); err != nil || string(data) != tarball {
+		t.Errorf("downloaded %d bytes, %v; want %d", len(data), err, len(tarball))
+	}
+	if seen != int64(len(tarball)) {
+		t.Errorf("progress ended at %d, want %d", seen, len(tarball))
+	}
+
+	err := downloadFile(silentLogger{}, nil, "sftp://zig@"+addr+filepath.ToSlash(src), dest, 1000, nil)
+	if err == nil || !strings.Contains(err.Error(), "more than --max-download-size") {
+		t.Errorf("a download over the size cap gave %v", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("the download over the size cap was left behind")
+	}
+
+	err = downloadFile(silentLogger{}, nil, "sftp://zig@"+addr+filepath.ToSlash(dir)+"/srv/missing.tar.xz", dest, 0, nil)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("a missing file gave %v", err)
+	}
+}
+
+func TestDownloadSSHUnknownHost(t *testing.T) {
+	addr := serveSSH(t, true)
+	dest := filepath.Join(t.TempDir(), "zig.tar.xz")
+	err := downloadFile(silentLogger{}, nil, "sftp://"+addr+"/zig.tar.xz", dest, 0, nil)
+	if err == nil || !strings.Contains(err.Error(), "not in known_hosts") {
+		t.Errorf("an unknown host gave %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxDownloadSize is far above any Zig tarball while still keeping
+// a bogus index from filling the disk.
+const defaultMaxDownloadSize = 2 * 1000 * 1000 * 1000
+
+// sizeFlag is a flag.Value holding a byte count such as 2GB or 500MB, in
+// the same decimal units formatBytes prints.
+type sizeFlag struct {
+	size *int64
+}
+
+func (f sizeFlag) String() string {
+	if f.size == nil {
+		return ""
+	}
+	return formatBytes(*f.size)
+}
+
+func (f sizeFlag) Set(s string) error {
+	n, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	*f.size = n
+	return nil
+}
+
+// parseSize parses a byte count with an optional k, M, G or T suffix
+// (with or without a trailing B).
+func parseSize(s string) (int64, error) {
+	num := strings.TrimSuffix(strings.TrimSpace(s), "B")
+	mult := int64(1)
+	if n := len(num); n > 0 {
+		if i := strings.IndexByte("kMGT", num[n-1]); i >= 0 {
+			num = strings.TrimSpace(num[:n-1])
+			for ; i >= 0; i-- {
+				mult *= 1000
+			}
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q: expected bytes or a value such as 500MB or 2GB", s)
+	}
+	return int64(v * float64(mult)), nil
+}
+
+// checkDownloadSize refuses an artifact whose size in the index is above
+// maxSize, before any of it is downloaded.
+func checkDownloadSize(a Artifact, maxSize int64) error {
+	if maxSize > 0 && a.Size > maxSize {
+		return fmt.Errorf("%s is %s according to the index, more than --max-download-size %s", a.Tarball, formatBytes(a.Size), formatBytes(maxSize))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// statusOutput is the --json shape of the status command.
+type statusOutput struct {
+	Manifest
+	Running  string `json:"running,omitempty"`
+	RunError string `json:"run_error,omitempty"`
+}
+
+func runStatus(log *Logger, args []string) error {
+	var cfg Config
+	fs := newCommandFlags("status", "status [flags]", &cfg)
+	if _, err := parseCommand(log, fs, &cfg, args); err != nil {
+		return err
+	}
+
+	m, err := readManifest(manifestPath(filepath.Join(cfg.rooted(cfg.LibDir), cfg.BinName)))
+	if err != nil {
+		return fmt.Errorf("no managed install found in %s", cfg.LibDir)
+	}
+
+	running, runErr := zigVersionOf(cfg.rooted(m.BinPath))
+	if cfg.JSON {
+		out := statusOutput{Manifest: m, Running: running}
+		if runErr != nil {
+			out.RunError = runErr.Error()
+		}
+		return printJSON(out)
+	}
+
+	fmt.Printf("Zig %s\n", m.Version)
+	if m.Requested != m.Version {
+		printField("requested", m.Requested)
+	}
+	printField("platform", m.Platform)
+	printField("binary", m.BinPath)
+	printField("lib", m.LibPath)
+	if m.Dir != "" && m.Dir != m.LibPath {
+		printField("dir", m.Dir)
+	}
+	printField("installed", m.InstalledAt.Local().Format("2006-01-02 15:04:05"))
+	printField("tarball", m.TarballURL)
+	printField("shasum", m.Shasum)
+	if m.ZLS != nil {
+		printField("zls", m.ZLS.Version+" ("+m.ZLS.BinPath+")")
+	}
+
+	switch {
+	case runErr != nil:
+		printField("running", fmt.Sprintf("failed: %v", runErr))
+	case running != m.Version:
+		printField("running", running+" (differs from manifest)")
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spinnerDelay is how long a step runs before it gets a spinner and a
+// completion line. Quicker steps stay as quiet as they always were.
+const spinnerDelay = time.Second
+
+// spinnerFrames animate the spinner; asciiSpinnerFrames are used with
+// --ascii.
+var (
+	spinnerFrames      = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	asciiSpinnerFrames = []string{"|", "/", "-", `\`}
+)
+
+// stepTimer remembers the step in progress, when it began and how long
+// the finished ones took. mu guards the terminal line shared by the
+// spinner, the progress bar and log lines.
+type stepTimer struct {
+	mu    sync.Mutex
+	name  string
+	start time.Time
+	// phases are the finished steps, for the summary of a run.
+	phases []phase
+	// out is where the spinner draws, and spinning is set while its
+	// line is on screen.
+	out      io.Writer
+	spinning bool
+	// drawn is when the progress bar last drew, which has the line
+	// rather than the spinner.
+	drawn time.Time
+	// stop ends the spinner goroutine, which closes done on its way out.
+	stop chan struct{}
+	done chan struct{}
+}
+
+// phase is a finished step and how long it took.
+type phase struct {
+	name     string
+	duration time.Duration
+}
+
+// stepCounter numbers the steps of a flow as [3/6], so progress through
+// it shows without a terminal too.
+type stepCounter struct {
+	log   Logger
+	n     int
+	total int
+}
+
+// step announces the next step with its number.
+func (c *stepCounter) step(format string, a ...interface{}) {
+	c.n++
+	c.log.Step("[%d/%d] "+format, append([]interface{}{c.n, c.total}, a...)...)
+}
+
+// stepNumber matches the number stepCounter puts in front of a step,
+// which step names leave out.
+var stepNumber = regexp.MustCompile(`^\[\d+/\d+\] `)
+
+// current returns the name of the step in progress, or "".
+func (t *stepTimer) current() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.name
+}
+
+// clearLine wipes the spinner off the screen. mu must be held.
+func (t *stepTimer) clearLine() {
+	if t.spinning {
+		fmt.Fprint(t.out, "\r\033[K")
+		t.spinning = false
+	}
+}
+
+// startStep starts timing the step called name, ending the previous one.
+// On terminals a step that runs past spinnerDelay gets a spinner with the
+// time elapsed, so extraction and large copies don't look like hangs.
+// step announces and starts steps; long quiet ones like the index fetch
+// call startStep directly.
+func (l consoleLogger) startStep(name string) {
+	l.endStep()
+	if l.steps == nil {
+		return
+	}
+	t := l.steps
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.name, t.start, t.out = name, time.Now(), l.out
+	if l.level < levelNormal || l.json || deterministic || !isTerminal(l.out) {
+		return
+	}
+	frames := spinnerFrames
+	if l.ascii {
+		frames = asciiSpinnerFrames
+	}
+	t.stop, t.done = make(chan struct{}), make(chan struct{})
+	go t.spin(frames, t.stop, t.done)
+}
+
+// timedLogger is a Logger that times steps itself, as the console logger
+// does for its spinner and the summary of a run.
+type timedLogger interface {
+	startStep(name string)
+	endStep()
+}
+
+// startTimedStep starts timing name on loggers that time steps, for long
+// quiet steps that announce nothing.
+func startTimedStep(log Logger, name string) {
+	if t, ok := log.(timedLogger); ok {
+		t.startStep(name)
+	}
+}
+
+// endTimedStep finishes the step started by startTimedStep.
+func endTimedStep(log Logger) {
+	if t, ok := log.(timedLogger); ok {
+		t.endStep()
+	}
+}
+
+// spin redraws the spinner until stop is closed.
+func (t *stepTimer) spin(frames []string, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		t.mu.Lock()
+		elapsed := time.Since(t.start)
+		if elapsed >= spinnerDelay && time.Since(t.drawn) >= 500*time.Millisecond {
+			fmt.Fprintf(t.out, "\r   %s %s %.1fs\033[K", frames[i%len(frames)], t.name, elapsed.Seconds())
+			t.spinning = true
+		}
+		t.mu.Unlock()
+	}
+}
+
+// stopSpinner takes the spinner off the screen for good, leaving the
+// step running.
+func (l consoleLogger) stopSpinner() {
+	if l.steps == nil {
+		return
+	}
+	t := l.steps
+	t.mu.Lock()
+	stop, done := t.stop, t.done
+	t.stop, t.done = nil, nil
+	t.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	t.mu.Lock()
+	t.clearLine()
+	t.mu.Unlock()
+}
+
+// endStep finishes the step in progress. One that ran past spinnerDelay
+// gets a completion line with its duration, any other says how long it
+// took with --verbose. Neither is logged in --deterministic runs, whose
+// output must not vary.
+func (l consoleLogger) endStep() {
+	if l.steps == nil || l.steps.current() == "" {
+		return
+	}
+	l.stopSpinner()
+	t := l.steps
+	t.mu.Lock()
+	name, took := t.name, time.Since(t.start).Round(time.Millisecond)
+	t.phases = append(t.phases, phase{name, took})
+	t.name = ""
+	t.mu.Unlock()
+
+	switch {
+	case deterministic:
+	case took >= spinnerDelay && l.level >= levelNormal && !l.json:
+		l.record("step", "%s took %s", name, took)
+		fmt.Fprintf(l.out, "%s   %s took %s\n", l.stamp(), name, took.Round(100*time.Millisecond))
+	default:
+		l.debug("%s took %s", name, took)
+	}
+}
+
+// stepSummary logs how long each finished step took, outside
+// --deterministic runs. Runs quicker than spinnerDelay only get it with
+// --verbose.
+func (l consoleLogger) stepSummary() {
+	l.endStep()
+	if l.steps == nil || deterministic {
+		return
+	}
+	var total time.Duration
+	var parts []string
+	for _, p := range l.steps.phases {
+		total += p.duration
+		parts = append(parts, fmt.Sprintf("%s %s", p.name, p.duration))
+	}
+	switch {
+	case len(parts) == 0:
+	case total >= spinnerDelay:
+		l.info("took %s: %s", total.Round(100*time.Millisecond), strings.Join(parts, ", "))
+	default:
+		l.debug("took %s: %s", total, strings.Join(parts, ", "))
+	}
+}
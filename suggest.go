@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is how many alternatives are offered for a typo.
+const maxSuggestions = 3
+
+// suggestVersions returns up to maxSuggestions index keys close to the
+// requested one. Keys the request is a prefix of come first, newest first,
+// so "0.13" suggests the newest 0.13.x; the rest are ranked by edit
+// distance, newest first among equally close ones.
+func suggestVersions(index Index, requested string) []string {
+	keys := index.Keys()
+
+	var prefixed []string
+	for _, k := range keys {
+		if strings.HasPrefix(k, requested+".") || strings.HasPrefix(k, requested+"-") {
+			prefixed = append(prefixed, k)
+		}
+	}
+	var suggestions []string
+	for _, v := range sortedVersions(prefixed) {
+		suggestions = append(suggestions, v.String())
+	}
+	if len(suggestions) >= maxSuggestions {
+		return suggestions[:maxSuggestions]
+	}
+
+	type candidate struct {
+		key      string
+		distance int
+	}
+	var candidates []candidate
+	for _, k := range keys {
+		if strings.HasPrefix(k, requested+".") || strings.HasPrefix(k, requested+"-") {
+			continue
+		}
+		// Anything that needs more edits than half its length isn't a typo
+		d := editDistance(requested, k)
+		if d > (len(k)+1)/2 {
+			continue
+		}
+		candidates = append(candidates, candidate{k, d})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		// Ties go to the newest version, then to named keys
+		a, aErr := parseVersion(candidates[i].key)
+		b, bErr := parseVersion(candidates[j].key)
+		switch {
+		case aErr == nil && bErr == nil:
+			return compareVersions(a, b) > 0
+		case (aErr == nil) != (bErr == nil):
+			return aErr == nil
+		}
+		return candidates[i].key > candidates[j].key
+	})
+	for _, c := range candidates {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, c.key)
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// notFoundHint describes what to try instead of an unknown version.
+func notFoundHint(index Index, requested string) string {
+	hint := "see `zig-installer list` for all versions"
+	if s := suggestVersions(index, requested); len(s) > 0 {
+		hint = "did you mean " + strings.Join(s, ", ") + "? " + hint
+	}
+	return hint
+}
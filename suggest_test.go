@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// suggestIndex is an index with the keys of several release series.
+const suggestIndex = `{
+	"master": {"version": "0.14.0-dev.2345+abcdef012"},
+	"0.13.0": {},
+	"0.12.1": {},
+	"0.12.0": {},
+	"0.11.0": {},
+	"0.10.1": {},
+	"0.10.0": {},
+	"0.9.1": {}
+}`
+
+func TestSuggestVersions(t *testing.T) {
+	index, err := parseIndex([]byte(suggestIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		requested string
+		want      []string
+	}{
+		{"0.13", []string{"0.13.0", "0.12.1", "0.12.0"}},
+		{"0.12", []string{"0.12.1", "0.12.0", "0.13.0"}},
+		{"0.12.0.1", []string{"0.12.1", "0.12.0", "0.10.1"}},
+		{"0.13.1", []string{"0.13.0", "0.12.1", "0.10.1"}},
+		{"mastr", []string{"master"}},
+		{"nightly", nil},
+		{"1.0.0", []string{"0.10.0", "0.13.0", "0.12.0"}},
+	}
+	for _, tt := range tests {
+		if got := suggestVersions(index, tt.requested); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suggestVersions(%q) = %q, want %q", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestNotFoundHint(t *testing.T) {
+	index, err := parseIndex([]byte(suggestIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := notFoundHint(index, "0.13"); !strings.HasPrefix(got, "did you mean 0.13.0") || !strings.HasSuffix(got, "`zig-installer list` for all versions") {
+		t.Errorf("notFoundHint(0.13) = %q", got)
+	}
+	if got := notFoundHint(index, "nightly"); got != "see `zig-installer list` for all versions" {
+		t.Errorf("notFoundHint(nightly) = %q", got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"0.13.0", "0.13.0", 0},
+		{"0.13", "0.13.0", 2},
+		{"0.12.0", "0.13.0", 1},
+		{"mastr", "master", 1},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// verifyTarball checks a local file against the checksum and size the
+// index publishes for the requested version and the host platform.
+func verifyTarball(cfg Config) error {
+	index, err := loadIndex(cfg, cfg.Version)
+	if err != nil {
+		return err
+	}
+	version, err := resolveVersion(index, cfg.Version, cfg.Prerelease)
+	if err != nil {
+		return err
+	}
+	platformKey, err := platformIn(cfg, index, version)
+	if err != nil {
+		return err
+	}
+	if err := checkPlatformAxes(cfg, index); err != nil {
+		return err
+	}
+	platformKey = pickARMVariant(cfg, index[version], platformKey)
+	artifact, err := index.artifactFor(cfg.log(), version, platformKey, cfg.CPUVariant)
+	if err != nil {
+		return err
+	}
+
+	if err := crossCheckShasum(artifact.Shasum, cfg.ExpectedSHA256); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(cfg.VerifyTarball)
+	if err != nil {
+		return newError(kindFilesystem, "%v", err)
+	}
+
+	cfg.log().Step("verifying %s against Zig %s for %s...", cfg.VerifyTarball, version, platformKey)
+	if artifact.Size > 0 && info.Size() != artifact.Size {
+		cfg.log().Error("TAMPERED: size mismatch: expected %d bytes, got %d", artifact.Size, info.Size())
+		return exitCode(exitChecksum)
+	}
+	if err := verifyChecksum(cfg.VerifyTarball, artifact.Shasum); err != nil {
+		cfg.log().Error("TAMPERED: %v", err)
+		return exitCode(exitChecksum)
+	}
+	cfg.log().Success("GENUINE: %s matches the official Zig %s artifact (%s)", cfg.VerifyTarball, version, artifact.Shasum)
+	return nil
+}
+
+// crossCheckShasum makes sure a checksum pinned with --expected-sha256
+// agrees with the one the index publishes. Checking this before the
+// download catches a pin that drifted from upstream (or an index that did).
+func crossCheckShasum(indexSum, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	expected = strings.ToLower(expected)
+	if err := validateShasum(expected); err != nil {
+		return newError(kindUsage, "--expected-sha256: %v", err)
+	}
+	if expected != indexSum {
+		return newError(kindChecksum, "index shasum %s does not match --expected-sha256 %s", indexSum, expected)
+	}
+	return nil
+}
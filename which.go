@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// whichOutput is the --json shape of --which.
+type whichOutput struct {
+	Path      string `json:"path"`
+	Installed bool   `json:"installed"`
+	// Link is the symlink target, as --bin-name installs have.
+	Link     string `json:"link,omitempty"`
+	Version  string `json:"version,omitempty"`
+	RunError string `json:"run_error,omitempty"`
+	// OnPath is what "zig" runs from PATH when that is another binary.
+	OnPath string `json:"on_path,omitempty"`
+}
+
+// printWhich reports the managed binary for --which: where it is, what a
+// symlink points at, what it says its version is, and whether another
+// zig comes first on PATH. It exits with the not found code when nothing
+// is installed there.
+func printWhich(cfg Config) error {
+	path := filepath.Join(cfg.BinDir, binaryFile(cfg.BinName, getPlatformKey()))
+	out := whichOutput{Path: path}
+
+	info, err := os.Lstat(cfg.rooted(path))
+	if err == nil {
+		out.Installed = true
+		if info.Mode()&os.ModeSymlink != 0 {
+			out.Link, _ = os.Readlink(cfg.rooted(path))
+		}
+		out.Version, err = zigVersionOf(cfg.rooted(path))
+		if err != nil {
+			out.RunError = err.Error()
+		}
+		if cfg.Root == "" {
+			if found, err := exec.LookPath(cfg.BinName); err == nil && !sameFile(found, path) {
+				out.OnPath = found
+			}
+		}
+	}
+
+	if cfg.JSON {
+		if err := printJSON(out); err != nil {
+			return err
+		}
+		if !out.Installed {
+			return exitCode(exitNotFound)
+		}
+		return nil
+	}
+	if !out.Installed {
+		return newError(kindNotFound, "no Zig installed at %s", path)
+	}
+
+	fmt.Println(path)
+	if out.Link != "" {
+		printField("symlink", "-> "+out.Link)
+	}
+	if out.RunError != "" {
+		printField("version", fmt.Sprintf("failed to run: %s", out.RunError))
+	} else {
+		printField("version", out.Version)
+	}
+	if out.OnPath != "" {
+		printField("PATH", fmt.Sprintf("plain %s runs %s instead", cfg.BinName, out.OnPath))
+	}
+	return nil
+}
+
+// sameFile reports whether a and b name the same file, following links.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
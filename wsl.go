@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// inWSL reports whether the installer runs under the Windows Subsystem
+// for Linux, where Windows drives are mounted below /mnt and the Windows
+// Path is appended to PATH.
+var inWSL = sync.OnceValue(func() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	data, err := os.ReadFile("/proc/version")
+	return err == nil && strings.Contains(strings.ToLower(string(data)), "microsoft")
+})
+
+// onWindowsDrive reports whether path lies on a Windows drive mounted by
+// WSL, such as /mnt/c.
+func onWindowsDrive(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rest, ok := strings.CutPrefix(abs, "/mnt/")
+	if !ok || len(rest) == 0 || rest[0] < 'a' || rest[0] > 'z' {
+		return false
+	}
+	return len(rest) == 1 || rest[1] == '/'
+}
+
+// checkWSLStaging warns when the download or extraction happens on a
+// Windows drive, which is much slower than the Linux filesystem and
+// cannot hold the symlinks and permissions of the tarball.
+func checkWSLStaging(cfg Config) {
+	if !inWSL() {
+		return
+	}
+	for _, dir := range []string{cfg.TarDest, cfg.Dest} {
+		if onWindowsDrive(dir) {
+			cfg.log().Warning("%s is on a Windows drive, which is slow under WSL and drops permissions; point --tar-dest and --dest at the Linux filesystem, e.g. /tmp", dir)
+			return
+		}
+	}
+}
+
+// checkWSLShadowing warns about a Windows install of zig that runs instead
+// of the one in binDir because WSL's interop appends the Windows Path to
+// PATH. Both a zig.exe and an extensionless shim such as Scoop's count.
+func checkWSLShadowing(log Logger, binDir, binName string) {
+	if !inWSL() {
+		return
+	}
+	want := filepath.Clean(binDir)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(dir) == want {
+			return
+		}
+		if !onWindowsDrive(dir) {
+			continue
+		}
+		for _, name := range []string{binName, binName + ".exe"} {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				log.Warning("%s from the Windows Path runs instead of %s through WSL interop; remove it from the Windows Path, or set appendWindowsPath = false under [interop] in /etc/wsl.conf", path, binDir)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultZLSIndexURL  = "https://builds.zigtools.org/index.json"
+	defaultZLSSelectURL = "https://releases.zigtools.org/v1/zls/select-version"
+)
+
+// ZLSInstall records a zls binary installed next to a Zig install.
+type ZLSInstall struct {
+	Version     string    `json:"version"`
+	ZigVersion  string    `json:"zig_version"`
+	TarballURL  string    `json:"tarball_url"`
+	Shasum      string    `json:"shasum"`
+	BinPath     string    `json:"bin_path"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// zlsOptions are the flags of the zls subcommands.
+type zlsOptions struct {
+	indexURL  string
+	selectURL string
+	platform  string
+}
+
+func newZLSFlags(name, usage string, cfg *Config, opts *zlsOptions) *flag.FlagSet {
+	fs := newCommandFlags("zls "+name, "zls "+usage, cfg)
+	fs.StringVar(&opts.indexURL, "zls-index-url", getEnv("ZIG_ZLS_INDEX_URL", defaultZLSIndexURL), "URL for the zls release index")
+	fs.StringVar(&opts.selectURL, "zls-select-url", getEnv("ZIG_ZLS_SELECT_URL", defaultZLSSelectURL), "URL of the zigtools version selection API")
+	fs.StringVar(&opts.platform, "platform", getPlatformKey(), "Platform key to show the artifact for")
+	return fs
+}
+
+func runZLS(log *Logger, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return runZLSList(log, args[1:])
+		case "info":
+			return runZLSInfo(log, args[1:])
+		case "install":
+			return runZLSInstall(log, args[1:])
+		}
+	}
+	return newError(kindUsage, "usage: zls list|info|install [flags] [zig-version]")
+}
+
+func runZLSList(log *Logger, args []string) error {
+	var cfg Config
+	var opts zlsOptions
+	fs := newZLSFlags("list", "list [flags]", &cfg, &opts)
+	if _, err := parseCommand(log, fs, &cfg, args); err != nil {
+		return err
+	}
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	index, err := fetchIndexCached(cfg, client, opts.indexURL)
+	if err != nil {
+		return err
+	}
+	versions := remoteVersions(index, "zigtools", opts.platform)
+
+	if cfg.JSON {
+		return printJSON(versions)
+	}
+	for _, v := range versions {
+		line := fmt.Sprintf("%-24s %s", v.Key, v.Date)
+		if !v.Available {
+			line += " [no " + opts.platform + " build]"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// zlsInfo is the --json shape of zls info.
+type zlsInfo struct {
+	ZigVersion string    `json:"zig_version"`
+	Version    string    `json:"version"`
+	Date       string    `json:"date,omitempty"`
+	Platform   string    `json:"platform"`
+	Artifact   *Artifact `json:"artifact,omitempty"`
+}
+
+func runZLSInfo(log *Logger, args []string) error {
+	var cfg Config
+	var opts zlsOptions
+	fs := newZLSFlags("info", "info [flags] [zig-version]", &cfg, &opts)
+	positional, err := parseCommand(log, fs, &cfg, args)
+	if err != nil {
+		return err
+	}
+	zigVersion, err := zlsZigVersion(cfg, positional)
+	if err != nil {
+		return err
+	}
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	entry, err := selectZLS(cfg.log(), client, opts.selectURL, zigVersion, cfg.IndexTimeout)
+	if err != nil {
+		return err
+	}
+
+	info := zlsInfo{ZigVersion: zigVersion, Version: entry.Version, Date: entry.Date, Platform: opts.platform}
+	if a, ok := entry.Artifacts[opts.platform]; ok {
+		info.Artifact = &a
+	}
+	if cfg.JSON {
+		return printJSON(info)
+	}
+
+	fmt.Printf("zls %s\n", info.Version)
+	printField("for zig", zigVersion)
+	printField("date", info.Date)
+	printField("platform", opts.platform)
+	if info.Artifact == nil {
+		fmt.Printf("  (no artifact for %s)\n", opts.platform)
+		return nil
+	}
+	printField("tarball", info.Artifact.Tarball)
+	printField("size", formatBytes(info.Artifact.Size))
+	printField("shasum", info.Artifact.Shasum)
+	return nil
+}
+
+func runZLSInstall(log *Logger, args []string) error {
+	var cfg Config
+	var opts zlsOptions
+	fs := newZLSFlags("install", "install [flags] [zig-version]", &cfg, &opts)
+	positional, err := parseCommand(log, fs, &cfg, args)
+	if err != nil {
+		return err
+	}
+	zigVersion, err := zlsZigVersion(cfg, positional)
+	if err != nil {
+		return err
+	}
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	cfg.Progress = cfg.log().Progress()
+	z, err := installZLS(cfg, client, opts, zigVersion)
+	if err != nil {
+		return err
+	}
+
+	// Record zls in the manifest of the Zig install it belongs to
+	libPath := filepath.Join(cfg.rooted(cfg.LibDir), cfg.BinName)
+	if m, err := readManifest(manifestPath(libPath)); err == nil && m.Version == zigVersion {
+		m.ZLS = z
+		if err := writeManifest(manifestPath(libPath), m); err != nil {
+			cfg.log().Warning("failed to write manifest: %v", err)
+		}
+	}
+
+	if cfg.JSON {
+		return printJSON(z)
+	}
+	return nil
+}
+
+// zlsZigVersion returns the Zig version to pick zls for: the argument, or
+// the version of the managed Zig install.
+func zlsZigVersion(cfg Config, positional []string) (string, error) {
+	if len(positional) > 0 {
+		return positional[0], nil
+	}
+	m, err := readManifest(manifestPath(filepath.Join(cfg.rooted(cfg.LibDir), cfg.BinName)))
+	if err != nil {
+		return "", newError(kindUsage, "no managed Zig install found in %s; pass the Zig version to pick zls for", cfg.LibDir)
+	}
+	return m.Version, nil
+}
+
+// selectZLS asks the zigtools API for the zls release compatible with
+// zigVersion. The answer has the layout of a single index entry.
+func selectZLS(log Logger, client *http.Client, selectURL, zigVersion string, timeout time.Duration) (VersionEntry, error) {
+	u, err := url.Parse(selectURL)
+	if err != nil {
+		return VersionEntry{}, newError(kindUsage, "invalid --zls-select-url %q: %v", selectURL, err)
+	}
+	q := u.Query()
+	q.Set("zig_version", zigVersion)
+	q.Set("compatibility", "only-runtime")
+	u.RawQuery = q.Encode()
+
+	resp, err := fetchIndexData(log, client, u.String(), "", timeout)
+	if err != nil {
+		return VersionEntry{}, err
+	}
+	data := resp.Data
+
+	// Unsupported versions are answered with an error object
+	var apiErr struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+		return VersionEntry{}, newError(kindNotFound, "no zls release for Zig %s: %s", zigVersion, apiErr.Message)
+	}
+
+	var entry VersionEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return VersionEntry{}, fmt.Errorf("invalid zls release metadata: %v", err)
+	}
+	if entry.Version == "" {
+		return VersionEntry{}, fmt.Errorf("invalid zls release metadata: missing version")
+	}
+	return entry, nil
+}
+
+// installZLS downloads, verifies and installs the zls release compatible
+// with zigVersion into cfg.BinDir.
+func installZLS(cfg Config, client *http.Client, opts zlsOptions, zigVersion string) (*ZLSInstall, error) {
+	entry, err := selectZLS(cfg.log(), client, opts.selectURL, zigVersion, cfg.IndexTimeout)
+	if err != nil {
+		return nil, err
+	}
+	artifact, ok := entry.Artifacts[opts.platform]
+	if !ok {
+		return nil, &kindError{kind: kindNotFound, err: &platformError{
+			product:  "zls",
+			version:  entry.Version,
+			platform: opts.platform,
+			host:     getPlatformKey(),
+			offered:  entry.platforms(),
+		}}
+	}
+	if err := validateTarballURL(artifact.Tarball, opts.indexURL+","+opts.selectURL, nil, cfg.AllowForeignHost); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.MkdirTemp("", "zls-")
+	if err != nil {
+		return nil, newError(kindFilesystem, "failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	tarball := filepath.Join(tmp, filepath.Base(artifact.Tarball))
+	cfg.log().Step("downloading zls %s for Zig %s...", entry.Version, zigVersion)
+	if err := checkDownloadSize(artifact, cfg.MaxDownloadSize); err != nil {
+		return nil, err
+	}
+	if err := downloadFile(cfg.log(), client, artifact.Tarball, tarball, cfg.MaxDownloadSize, cfg.Progress); err != nil {
+		return nil, newError(kindNetwork, "failed to download zls: %v", err)
+	}
+	cfg.log().Step("verifying checksum...")
+	if err := verifyChecksum(tarball, artifact.Shasum); err != nil {
+		return nil, newError(kindChecksum, "checksum verification failed: %w", err)
+	}
+
+	extracted := filepath.Join(tmp, "zls")
+	if err := extractArchive(cfg.log(), tarball, extracted, 0, cfg.Decompressor, cfg.Progress); err != nil {
+		return nil, fmt.Errorf("failed to extract zls: %w", err)
+	}
+	src, err := findFile(extracted, "zls")
+	if err != nil {
+		return nil, err
+	}
+
+	binDir := cfg.rooted(cfg.BinDir)
+	if err := ensureInstallDir(binDir, cfg.DirMode); err != nil {
+		return nil, newError(kindFilesystem, "failed to create bin directory: %v", err)
+	}
+	binPath := filepath.Join(binDir, "zls")
+	os.Remove(binPath)
+	if err := moveFile(src, binPath); err != nil {
+		return nil, newError(kindFilesystem, "failed to install zls: %v", err)
+	}
+	if err := os.Chmod(binPath, cfg.FileMode); err != nil {
+		return nil, newError(kindFilesystem, "failed to set permissions: %v", err)
+	}
+
+	cfg.log().Success("zls %s installed to %s", entry.Version, binPath)
+	return &ZLSInstall{
+		Version:     entry.Version,
+		ZigVersion:  zigVersion,
+		TarballURL:  artifact.Tarball,
+		Shasum:      artifact.Shasum,
+		BinPath:     filepath.Join(cfg.BinDir, "zls"),
+		InstalledAt: now().UTC(),
+	}, nil
+}
+
+// findFile returns the first regular file called name below dir, since
+// zls archives have not always had a top-level directory.
+func findFile(dir, name string) (string, error) {
+	var found string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if found == "" && d.Type().IsRegular() && d.Name() == name {
+			found = path
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", fmt.Errorf("archive contains no %s binary", name)
+	}
+	return found, nil
+}
+
+// upgradeZLS reinstalls zls after cfg's Zig install was upgraded and
+// records it in the new manifest.
+func upgradeZLS(cfg Config, previous *ZLSInstall) error {
+	libPath := filepath.Join(cfg.rooted(cfg.LibDir), cfg.BinName)
+	m, err := readManifest(manifestPath(libPath))
+	if err != nil {
+		return err
+	}
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	cfg.BinDir = filepath.Dir(previous.BinPath)
+	opts := zlsOptions{
+		indexURL:  getEnv("ZIG_ZLS_INDEX_URL", defaultZLSIndexURL),
+		selectURL: getEnv("ZIG_ZLS_SELECT_URL", defaultZLSSelectURL),
+		platform:  m.Platform,
+	}
+	if m.ZLS, err = installZLS(cfg, client, opts, m.Version); err != nil {
+		return err
+	}
+	return writeManifest(manifestPath(libPath), m)
+}